@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
 	handlers "github.com/jrmanes/torch/pkg/http"
 	"github.com/jrmanes/torch/pkg/k8s"
 )
@@ -21,21 +30,29 @@ func ParseFlags() config.MutualPeersConfig {
 	// Parse the flags
 	flag.Parse()
 
+	cfg, _ := ReadConfig(*configFile)
+	return cfg
+}
+
+// ReadConfig reads the configuration file.
+func ReadConfig(configFile string) (config.MutualPeersConfig, error) {
+	cfg := config.MutualPeersConfig{}
+
 	// Read the configuration file
-	file, err := os.ReadFile(*configFile)
+	file, err := os.ReadFile(configFile)
 	if err != nil {
 		log.Error("Config file doesn't exist...", err)
+		return cfg, err
 	}
 
-	cfg := config.MutualPeersConfig{}
-
 	// Unmarshal the YAML into a struct
 	err = yaml.Unmarshal(file, &cfg)
 	if err != nil {
 		log.Error("Cannot unmarshal the config file...", err)
+		return cfg, err
 	}
 
-	return cfg
+	return cfg, nil
 }
 
 func PrintName() {
@@ -51,7 +68,242 @@ func PrintName() {
 	fmt.Println(torch)
 }
 
+// cliTimeout max time to run the commands against the DB.
+const cliTimeout = 60 * time.Second
+
+// RunExport dumps all the nodes stored in the DB into a file or the standard output.
+func RunExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("output", "", "Path to the file to write the backup, standard output if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	backup, err := redis.Export(redis.InitRedisConfig(), ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	log.Info("Writing the backup to: ", *output)
+	return os.WriteFile(*output, data, 0o600)
+}
+
+// RunImport loads a backup file into the DB, or sends it to the import endpoint of Torch when its URL is set, so the
+// running server refreshes the multiaddr metrics of the nodes imported.
+func RunImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	input := fs.String("input", "", "Path to the backup file to import")
+	modeFlag := fs.String("mode", string(redis.ImportModeMerge), "Import mode: merge or overwrite")
+	dryRun := fs.Bool("dry-run", false, "List the changes without applying them")
+	torchURL := fs.String("url", "", "URL of Torch, e.g. http://torch:8080, to import the backup through its API")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mode, err := redis.ParseImportMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.ReadFile(*input)
+	if err != nil {
+		return err
+	}
+
+	backup := redis.Backup{}
+	if err := json.Unmarshal(file, &backup); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	if *torchURL != "" {
+		return importThroughAPI(ctx, *torchURL, file, mode, *dryRun)
+	}
+
+	result, err := redis.Import(redis.InitRedisConfig(), ctx, backup, mode, *dryRun)
+	if err != nil {
+		return err
+	}
+	if !*dryRun && len(result.Changes) > 0 {
+		log.Warn("The multiaddr metrics of the running Torch are not refreshed until it restarts, use --url to import the backup through its API")
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+
+	return nil
+}
+
+// importThroughAPI sends the backup to the import endpoint of Torch and prints the changes applied.
+func importThroughAPI(ctx context.Context, torchURL string, backup []byte, mode redis.ImportMode, dryRun bool) error {
+	query := url.Values{"mode": {string(mode)}, "dryRun": {strconv.FormatBool(dryRun)}}
+	endpoint := strings.TrimSuffix(torchURL, "/") + "/api/v1/import?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(backup))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	resp := handlers.Response{}
+	if err := json.NewDecoder(response.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("error decoding the response of [%s], status [%s]: %w", endpoint, response.Status, err)
+	}
+	if resp.Status != http.StatusOK {
+		return fmt.Errorf("error importing the backup through [%s]: %v", endpoint, resp.Errors)
+	}
+
+	data, err := json.MarshalIndent(resp.Body, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// RunManifests renders the manifests generated from the config, printing them as YAML or applying them.
+func RunManifests(args []string) error {
+	if len(args) == 0 || args[0] != "networkpolicy" {
+		return fmt.Errorf("unknown manifest, usage: torch manifests networkpolicy [flags]")
+	}
+
+	fs := flag.NewFlagSet("manifests networkpolicy", flag.ExitOnError)
+	configFile := fs.String("config-file", "", "Path to the configuration file")
+	cluster := fs.String("cluster", "", "Name of the cluster of the nodes, empty for the cluster where Torch runs")
+	apply := fs.Bool("apply", false, "Apply the NetworkPolicies in the cluster instead of printing them")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := ReadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	policies := k8s.BuildNetworkPolicies(cfg, *cluster)
+
+	if *apply {
+		ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+		defer cancel()
+
+		if err := k8s.RegisterClusters(ctx, cfg.Clusters); err != nil {
+			return err
+		}
+		return k8s.ApplyNetworkPolicies(k8s.WithCluster(ctx, *cluster), k8s.NetworkPolicyNamespaces(cfg, *cluster), policies)
+	}
+
+	data, err := k8s.NetworkPoliciesYAML(policies)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// RunDoctor diagnoses the setup of Torch, `doctor rbac` prints the minimal Role and RoleBinding that Torch needs for
+// the config, or checks the permissions of the current credentials with --check.
+func RunDoctor(args []string) error {
+	if len(args) == 0 || args[0] != "rbac" {
+		return fmt.Errorf("unknown diagnosis, usage: torch doctor rbac [flags]")
+	}
+
+	fs := flag.NewFlagSet("doctor rbac", flag.ExitOnError)
+	configFile := fs.String("config-file", "", "Path to the configuration file")
+	cluster := fs.String("cluster", "", "Name of the cluster of the nodes, empty for the cluster where Torch runs")
+	name := fs.String("name", "torch", "Name of the Roles and the RoleBindings")
+	serviceAccount := fs.String("service-account", "torch", "ServiceAccount used by Torch")
+	serviceAccountNamespace := fs.String("service-account-namespace", k8s.GetCurrentNamespace(), "Namespace of the ServiceAccount")
+	check := fs.Bool("check", false, "Check the permissions of the current credentials instead of printing the Roles")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := ReadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	if *check {
+		ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+		defer cancel()
+
+		if err := k8s.RegisterClusters(ctx, cfg.Clusters); err != nil {
+			return err
+		}
+		checks, err := k8s.CheckRBAC(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if err := k8s.WriteRBACReport(os.Stdout, checks); err != nil {
+			return err
+		}
+		if missing := k8s.MissingPermissions(checks); len(missing) > 0 {
+			return fmt.Errorf("[%d] RBAC permissions missing", len(missing))
+		}
+		return nil
+	}
+
+	roles, bindings := k8s.BuildRBAC(cfg, *cluster, *name, *serviceAccount, *serviceAccountNamespace)
+	data, err := k8s.RBACYAML(roles, bindings)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// RunCommand executes the subcommand received, it returns false if the args don't contain any subcommand.
+func RunCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "export":
+		return true, RunExport(args[1:])
+	case "import":
+		return true, RunImport(args[1:])
+	case "manifests":
+		return true, RunManifests(args[1:])
+	case "doctor":
+		return true, RunDoctor(args[1:])
+	}
+
+	return false, nil
+}
+
 func main() {
+	// Check if we have to run a subcommand instead of the server
+	ok, err := RunCommand(os.Args[1:])
+	if ok {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	PrintName()
 	// Parse the command-line flags and read the configuration file
 	log.Info("Running on namespace: ", k8s.GetCurrentNamespace())
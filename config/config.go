@@ -2,7 +2,25 @@ package config
 
 // MutualPeersConfig represents the configuration structure.
 type MutualPeersConfig struct {
-	MutualPeers []*MutualPeer `yaml:"mutualPeers"` // MutualPeers list of mutual peers.
+	MutualPeers []*MutualPeer `yaml:"mutualPeers"`         // MutualPeers list of mutual peers.
+	Discovery   Discovery     `yaml:"discovery,omitempty"` // Discovery specify how Torch discovers the nodes.
+	Clusters    []Cluster     `yaml:"clusters,omitempty"`  // Clusters list of remote clusters where the nodes run.
+}
+
+// Cluster represents a remote cluster where some of the nodes run, the credentials are read from a kubeconfig
+// context or from a Secret in the namespace of Torch.
+type Cluster struct {
+	Name       string `yaml:"name"`                 // Name of the cluster, referenced by the peers
+	Kubeconfig string `yaml:"kubeconfig,omitempty"` // Kubeconfig path of the kubeconfig, default: KUBECONFIG
+	Context    string `yaml:"context,omitempty"`    // Context of the kubeconfig, default: the current context
+	Secret     string `yaml:"secret,omitempty"`     // Secret name of the Secret with the kubeconfig
+	SecretKey  string `yaml:"secretKey,omitempty"`  // SecretKey key of the kubeconfig in the Secret, default: kubeconfig
+	Namespace  string `yaml:"namespace,omitempty"`  // Namespace watched in the cluster, default: the namespace of Torch
+}
+
+// Discovery represents how Torch discovers the nodes running in the cluster.
+type Discovery struct {
+	LabelSelector string `yaml:"labelSelector,omitempty"` // LabelSelector only the pods matching it are discovered
 }
 
 // MutualPeer represents a mutual peer structure.
@@ -23,5 +41,16 @@ type Peer struct {
 	ConnectsAsEnvVar   bool     `yaml:"connectsAsEnvVar,omitempty"`   // ConnectsAsEnvVar use the value as env var
 	ConnectsTo         []string `yaml:"connectsTo,omitempty"`         // ConnectsTo list of nodes that it will connect to
 	DnsConnections     []string `yaml:"dnsConnections,omitempty"`     // DnsConnections list of DNS records
-	RetryCount         int      `yaml:"retryCount,omitempty"`         // RetryCount number of retries
+	IPSource           string   `yaml:"ipSource,omitempty"`           // IPSource podIP (default) or hostIP without DNS
+	RetryCount         int      `yaml:"retryCount,omitempty"`         // RetryCount max number of retries, default: 5
+	Delivery           Delivery `yaml:"delivery,omitempty"`           // Delivery how the connections reach the node
+	ExternalService    string   `yaml:"externalService,omitempty"`    // ExternalService LoadBalancer service of the node
+	Cluster            string   `yaml:"cluster,omitempty"`            // Cluster where the node runs, default: the local one
+}
+
+// Delivery represents how Torch delivers the connections to the node.
+type Delivery struct {
+	Mode string `yaml:"mode,omitempty"` // Mode exec (default), configMap or secret
+	Name string `yaml:"name,omitempty"` // Name of the ConfigMap/Secret, default: torch-<nodeName>
+	Key  string `yaml:"key,omitempty"`  // Key of the connections, default: the file name, or the node name if Name is set
 }
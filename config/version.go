@@ -0,0 +1,23 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"gopkg.in/yaml.v2"
+)
+
+// versionLength number of characters of the hash used as version.
+const versionLength = 12
+
+// Version returns the version of the config, the hash of its content, so the nodes show which config was used to set
+// them up, empty if the config can't be encoded.
+func (c MutualPeersConfig) Version() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:versionLength]
+}
@@ -0,0 +1,257 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	BackupVersion      = 1                            // BackupVersion version of the backup format generated by Torch.
+	nodeMetadataPrefix = internalKeyPrefix + "meta::" // nodeMetadataPrefix prefix of the hashes with the metadata of the nodes.
+)
+
+// ImportMode specify how the records from a backup are applied to the DB.
+type ImportMode string
+
+const (
+	// ImportModeMerge adds the nodes that are missing in the DB and keeps the existing ones untouched.
+	ImportModeMerge ImportMode = "merge"
+	// ImportModeOverwrite makes the DB match the backup, updating and removing the nodes that differ.
+	ImportModeOverwrite ImportMode = "overwrite"
+)
+
+// Actions reported for every node when a backup is imported.
+const (
+	ActionAdd       = "add"       // ActionAdd the node doesn't exist in the DB and will be created.
+	ActionUpdate    = "update"    // ActionUpdate the node exists with a different value and will be replaced.
+	ActionDelete    = "delete"    // ActionDelete the node is not in the backup and will be removed.
+	ActionSkip      = "skip"      // ActionSkip the node exists with a different value and will be kept.
+	ActionUnchanged = "unchanged" // ActionUnchanged the node already has the same value.
+)
+
+// NodeRecord represents a node stored in the DB.
+type NodeRecord struct {
+	NodeName string            `json:"node_name"`          // NodeName name of the node.
+	NodeID   string            `json:"node_id"`            // NodeID value stored for the node.
+	Metadata map[string]string `json:"metadata,omitempty"` // Metadata extra information stored for the node.
+}
+
+// Backup represents the content of the DB exported by Torch.
+type Backup struct {
+	Version    int          `json:"version"`     // Version of the backup format.
+	ExportedAt time.Time    `json:"exported_at"` // ExportedAt time when the backup was generated.
+	Nodes      []NodeRecord `json:"nodes"`       // Nodes list of nodes stored in the DB.
+}
+
+// Change represents the action that an import performs on a node.
+type Change struct {
+	NodeName string `json:"node_name"`
+	Action   string `json:"action"`
+	Current  string `json:"current,omitempty"`
+	Desired  string `json:"desired,omitempty"`
+}
+
+// ImportResult summarizes the changes of an import.
+type ImportResult struct {
+	Mode    ImportMode `json:"mode"`
+	DryRun  bool       `json:"dry_run"`
+	Changes []Change   `json:"changes"`
+}
+
+// ParseImportMode validates the mode received, using merge by default.
+func ParseImportMode(mode string) (ImportMode, error) {
+	switch ImportMode(mode) {
+	case "", ImportModeMerge:
+		return ImportModeMerge, nil
+	case ImportModeOverwrite:
+		return ImportModeOverwrite, nil
+	}
+	return "", fmt.Errorf("invalid import mode [%s], must be %s or %s", mode, ImportModeMerge, ImportModeOverwrite)
+}
+
+// NodeMetadataKey returns the key of the hash with the metadata of the node.
+func NodeMetadataKey(nodeName string) string {
+	return nodeMetadataPrefix + nodeName
+}
+
+// GetNodeRecords returns all the nodes stored in the DB including their metadata.
+func GetNodeRecords(r *RedisClient, ctx context.Context) ([]NodeRecord, error) {
+	keys, err := r.GetAllKeys(ctx)
+	if err != nil {
+		log.Error("Error getting the keys from the DB: ", err)
+		return nil, err
+	}
+
+	records := make([]NodeRecord, 0, len(keys))
+	for nodeName, nodeID := range keys {
+		metadata, err := r.GetHash(ctx, NodeMetadataKey(nodeName))
+		if err != nil {
+			log.Error("Error getting the metadata for node: [", nodeName, "]: ", err)
+			return nil, err
+		}
+		if len(metadata) == 0 {
+			metadata = nil
+		}
+
+		records = append(records, NodeRecord{
+			NodeName: nodeName,
+			NodeID:   nodeID,
+			Metadata: metadata,
+		})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].NodeName < records[j].NodeName
+	})
+
+	return records, nil
+}
+
+// Export generates a backup with all the nodes stored in the DB.
+func Export(r *RedisClient, ctx context.Context) (Backup, error) {
+	records, err := GetNodeRecords(r, ctx)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	log.Info("Exporting [", len(records), "] nodes from the DB")
+
+	return Backup{
+		Version:    BackupVersion,
+		ExportedAt: time.Now().UTC(),
+		Nodes:      records,
+	}, nil
+}
+
+// Import applies the backup to the DB using the mode specified, if dryRun is true, it only returns the changes.
+func Import(r *RedisClient, ctx context.Context, backup Backup, mode ImportMode, dryRun bool) (ImportResult, error) {
+	if backup.Version != BackupVersion {
+		return ImportResult{}, fmt.Errorf("unsupported backup version [%d], expected [%d]", backup.Version, BackupVersion)
+	}
+
+	current, err := GetNodeRecords(r, ctx)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	changes, err := PlanImport(current, backup.Nodes, mode)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{
+		Mode:    mode,
+		DryRun:  dryRun,
+		Changes: changes,
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	desired := make(map[string]NodeRecord, len(backup.Nodes))
+	for _, n := range backup.Nodes {
+		desired[n.NodeName] = n
+	}
+
+	for _, c := range changes {
+		switch c.Action {
+		case ActionAdd, ActionUpdate:
+			n := desired[c.NodeName]
+			log.Info("Importing node: [", n.NodeName, "] - action: [", c.Action, "]")
+			if err := r.SetKey(ctx, n.NodeName, n.NodeID, nodeIdExpiration); err != nil {
+				log.Error("Error importing node: [", n.NodeName, "]: ", err)
+				return result, err
+			}
+			if err := r.SetHash(ctx, NodeMetadataKey(n.NodeName), n.Metadata); err != nil {
+				log.Error("Error importing the metadata for node: [", n.NodeName, "]: ", err)
+				return result, err
+			}
+		case ActionDelete:
+			log.Info("Removing node: [", c.NodeName, "]")
+			if err := r.DeleteKey(ctx, c.NodeName); err != nil {
+				log.Error("Error removing node: [", c.NodeName, "]: ", err)
+				return result, err
+			}
+			if err := r.DeleteKey(ctx, NodeMetadataKey(c.NodeName)); err != nil {
+				log.Error("Error removing the metadata for node: [", c.NodeName, "]: ", err)
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// PlanImport compares the nodes in the DB with the nodes in the backup and returns the changes to apply.
+func PlanImport(current, desired []NodeRecord, mode ImportMode) ([]Change, error) {
+	existing := make(map[string]NodeRecord, len(current))
+	for _, n := range current {
+		existing[n.NodeName] = n
+	}
+
+	var changes []Change
+	seen := make(map[string]bool, len(desired))
+	for _, n := range desired {
+		if n.NodeName == "" || n.NodeID == "" {
+			return nil, errors.New("invalid backup, every node must have a node_name and a node_id")
+		}
+		if seen[n.NodeName] {
+			return nil, fmt.Errorf("invalid backup, node [%s] is duplicated", n.NodeName)
+		}
+		seen[n.NodeName] = true
+
+		c := Change{NodeName: n.NodeName, Desired: n.NodeID}
+		old, ok := existing[n.NodeName]
+		switch {
+		case !ok:
+			c.Action = ActionAdd
+		case old.NodeID == n.NodeID && equalMetadata(old.Metadata, n.Metadata):
+			c.Action = ActionUnchanged
+			c.Current = old.NodeID
+		case mode == ImportModeOverwrite:
+			c.Action = ActionUpdate
+			c.Current = old.NodeID
+		default:
+			c.Action = ActionSkip
+			c.Current = old.NodeID
+		}
+		changes = append(changes, c)
+	}
+
+	// in overwrite mode, the nodes that are not in the backup are removed.
+	if mode == ImportModeOverwrite {
+		for _, n := range current {
+			if !seen[n.NodeName] {
+				changes = append(changes, Change{
+					NodeName: n.NodeName,
+					Action:   ActionDelete,
+					Current:  n.NodeID,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].NodeName < changes[j].NodeName
+	})
+
+	return changes, nil
+}
+
+// equalMetadata compares two metadata maps, nil and empty are equal.
+func equalMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanImport(t *testing.T) {
+	current := []NodeRecord{
+		{NodeName: "da-bridge-1-0", NodeID: "12D3KooWL8cqu7dFyodQNLWgJLuCzsQiv617SN9WDVX2GiZnjmeE"},
+		{NodeName: "da-bridge-2-0", NodeID: "12D3KooWNFpkX9fuo3GQ38FaVKdAZcTQsLr1BNE5DTHGjv2fjEHG"},
+	}
+	desired := []NodeRecord{
+		{NodeName: "da-bridge-1-0", NodeID: "12D3KooWL8cqu7dFyodQNLWgJLuCzsQiv617SN9WDVX2GiZnjmeE"},
+		{NodeName: "da-bridge-2-0", NodeID: "12D3KooWA26WDUmejZzU6XHc4C7KQNSWaEApe5BEyXFNchAqrxhA"},
+		{NodeName: "da-bridge-3-0", NodeID: "12D3KooWKsHCeUVJqJwymyi3bGt1Gwbn5uUUFi2N9WQ7G6rUSXig"},
+	}
+
+	type args struct {
+		current []NodeRecord
+		desired []NodeRecord
+		mode    ImportMode
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []Change
+		wantErr bool
+	}{
+		{
+			name: "Case 1: Merge keeps the existing nodes",
+			args: args{
+				current: current,
+				desired: desired,
+				mode:    ImportModeMerge,
+			},
+			want: []Change{
+				{NodeName: "da-bridge-1-0", Action: ActionUnchanged, Current: current[0].NodeID, Desired: desired[0].NodeID},
+				{NodeName: "da-bridge-2-0", Action: ActionSkip, Current: current[1].NodeID, Desired: desired[1].NodeID},
+				{NodeName: "da-bridge-3-0", Action: ActionAdd, Desired: desired[2].NodeID},
+			},
+		},
+		{
+			name: "Case 2: Overwrite updates and removes the nodes",
+			args: args{
+				current: current,
+				desired: desired[1:],
+				mode:    ImportModeOverwrite,
+			},
+			want: []Change{
+				{NodeName: "da-bridge-1-0", Action: ActionDelete, Current: current[0].NodeID},
+				{NodeName: "da-bridge-2-0", Action: ActionUpdate, Current: current[1].NodeID, Desired: desired[1].NodeID},
+				{NodeName: "da-bridge-3-0", Action: ActionAdd, Desired: desired[2].NodeID},
+			},
+		},
+		{
+			name: "Case 3: Metadata changes are detected",
+			args: args{
+				current: current[:1],
+				desired: []NodeRecord{
+					{NodeName: "da-bridge-1-0", NodeID: current[0].NodeID, Metadata: map[string]string{"external": "/ip4/1.2.3.4"}},
+				},
+				mode: ImportModeOverwrite,
+			},
+			want: []Change{
+				{NodeName: "da-bridge-1-0", Action: ActionUpdate, Current: current[0].NodeID, Desired: current[0].NodeID},
+			},
+		},
+		{
+			name: "Case 4: Duplicated nodes are rejected",
+			args: args{
+				desired: []NodeRecord{desired[0], desired[0]},
+				mode:    ImportModeMerge,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Case 5: Nodes without id are rejected",
+			args: args{
+				desired: []NodeRecord{{NodeName: "da-bridge-1-0"}},
+				mode:    ImportModeMerge,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlanImport(tt.args.current, tt.args.desired, tt.args.mode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PlanImport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlanImport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    ImportMode
+		wantErr bool
+	}{
+		{name: "Case 1: Default mode", mode: "", want: ImportModeMerge},
+		{name: "Case 2: Merge mode", mode: "merge", want: ImportModeMerge},
+		{name: "Case 3: Overwrite mode", mode: "overwrite", want: ImportModeOverwrite},
+		{name: "Case 4: Invalid mode", mode: "replace", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseImportMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseImportMode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseImportMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
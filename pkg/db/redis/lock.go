@@ -0,0 +1,165 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	lockKeyPrefix     = internalKeyPrefix + "lock::"  // lockKeyPrefix prefix of the keys used to lock the nodes.
+	fenceKeyPrefix    = internalKeyPrefix + "fence::" // fenceKeyPrefix prefix of the counters used as fencing tokens.
+	lockRetryInterval = 250 * time.Millisecond        // lockRetryInterval time to wait before trying to acquire a lock again.
+)
+
+// ErrLockNotHeld is returned when the lock expired or it was acquired by someone else.
+var ErrLockNotHeld = errors.New("the lock is not held anymore")
+
+var (
+	// acquireScript sets the lock if it doesn't exist and increments the fencing token of the resource.
+	acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+	// releaseScript removes the lock only if it is still held by the token received.
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	// refreshScript extends the TTL of the lock only if it is still held by the token received.
+	refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	// fencedSetScript sets the key only if the lock is still held by the token received.
+	fencedSetScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("SET", KEYS[2], ARGV[2], "PX", ARGV[3])
+end
+return false`)
+)
+
+// Lock represents a distributed lock over a resource, it can be shared between all the Torch replicas.
+type Lock struct {
+	client   *RedisClient
+	resource string        // resource name of the resource locked.
+	token    string        // token random value that identifies the owner of the lock.
+	ttl      time.Duration // ttl time to keep the lock if it is not refreshed.
+	Fence    int64         // Fence fencing token, it increases every time the lock of the resource is acquired.
+}
+
+// AcquireLock tries to acquire the lock of the resource until it succeeds or the context is done.
+func (r *RedisClient) AcquireLock(ctx context.Context, resource string, ttl time.Duration) (*Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	lockKey := lockKeyPrefix + resource
+	fenceKey := fenceKeyPrefix + resource
+
+	for {
+		fence, err := acquireScript.Run(ctx, r.client, []string{lockKey, fenceKey}, token, ttl.Milliseconds()).Int64()
+		if err != nil {
+			log.Error("Error acquiring the lock for: [", resource, "]: ", err)
+			return nil, err
+		}
+
+		if fence > 0 {
+			return &Lock{
+				client:   r,
+				resource: resource,
+				token:    token,
+				ttl:      ttl,
+				Fence:    fence,
+			}, nil
+		}
+
+		// the lock is held by someone else, wait and try again.
+		timer := time.NewTimer(lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Release removes the lock if it is still held.
+func (l *Lock) Release(ctx context.Context) error {
+	released, err := releaseScript.Run(ctx, l.client.client, []string{lockKeyPrefix + l.resource}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Refresh extends the TTL of the lock if it is still held.
+func (l *Lock) Refresh(ctx context.Context) error {
+	refreshed, err := refreshScript.Run(ctx, l.client.client, []string{lockKeyPrefix + l.resource}, l.token, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if refreshed == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Validate checks that the lock is still held and no one acquired it after us, it must be called before
+// modifying the resource.
+func (l *Lock) Validate(ctx context.Context) error {
+	token, err := l.client.GetKey(ctx, lockKeyPrefix+l.resource)
+	if err != nil {
+		return err
+	}
+	if token != l.token {
+		return ErrLockNotHeld
+	}
+
+	fence, err := l.client.client.Get(ctx, fenceKeyPrefix+l.resource).Int64()
+	if err != nil {
+		return err
+	}
+	if fence != l.Fence {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// SetKey stores the key only if the lock is still held, so a holder whose lock expired can't overwrite the value.
+func (l *Lock) SetKey(ctx context.Context, key, value string, expiration time.Duration) error {
+	err := fencedSetScript.Run(
+		ctx,
+		l.client.client,
+		[]string{lockKeyPrefix + l.resource, key},
+		l.token,
+		value,
+		expiration.Milliseconds(),
+	).Err()
+	if err == redis.Nil {
+		return ErrLockNotHeld
+	}
+	return err
+}
+
+// newLockToken generates a random token to identify the owner of a lock.
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestClient returns a client connected to an in memory Redis server.
+func newTestClient(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	return NewRedisClient(server.Addr(), "", 0), server
+}
+
+func TestAcquireLock(t *testing.T) {
+	red, server := newTestClient(t)
+	ctx := context.Background()
+
+	lock, err := red.AcquireLock(ctx, "da-bridge-1-0", time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	if lock.Fence != 1 {
+		t.Errorf("AcquireLock() fence = %v, want 1", lock.Fence)
+	}
+
+	// Case 1: the lock can't be acquired while it is held.
+	waitCtx, cancel := context.WithTimeout(ctx, 3*lockRetryInterval)
+	defer cancel()
+	if _, err := red.AcquireLock(waitCtx, "da-bridge-1-0", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AcquireLock() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Case 2: the holder can write while it holds the lock.
+	if err := lock.Validate(ctx); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := lock.SetKey(ctx, "da-bridge-1-0", "id-1", time.Hour); err != nil {
+		t.Errorf("SetKey() error = %v", err)
+	}
+
+	// Case 3: once the lock expires, a new holder gets a bigger fencing token and the old one can't write.
+	server.FastForward(2 * time.Minute)
+	newLock, err := red.AcquireLock(ctx, "da-bridge-1-0", time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	if newLock.Fence != 2 {
+		t.Errorf("AcquireLock() fence = %v, want 2", newLock.Fence)
+	}
+	if err := lock.Validate(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrLockNotHeld)
+	}
+	if err := lock.SetKey(ctx, "da-bridge-1-0", "id-2", time.Hour); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("SetKey() error = %v, want %v", err, ErrLockNotHeld)
+	}
+	if err := lock.Release(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("Release() error = %v, want %v", err, ErrLockNotHeld)
+	}
+
+	value, err := red.GetKey(ctx, "da-bridge-1-0")
+	if err != nil || value != "id-1" {
+		t.Errorf("GetKey() = %v, %v, want id-1", value, err)
+	}
+
+	// Case 4: the lock can be acquired again once it is released.
+	if err := newLock.Release(ctx); err != nil {
+		t.Errorf("Release() error = %v", err)
+	}
+	if _, err := red.AcquireLock(ctx, "da-bridge-1-0", time.Minute); err != nil {
+		t.Errorf("AcquireLock() error = %v", err)
+	}
+}
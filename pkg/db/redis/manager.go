@@ -7,6 +7,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// nodeIdExpiration time that Torch keeps the node ids in the DB.
+const nodeIdExpiration = 1000 * time.Hour
+
 // SetNodeId stores the values in redis.
 func SetNodeId(
 	podName string,
@@ -24,7 +27,7 @@ func SetNodeId(
 	// if the node is not in the db, then we add it
 	if nodeName == "" {
 		log.Info("Node ", "["+podName+"]"+" not found in Redis, let's add it")
-		err := r.SetKey(ctx, podName, output, 1000*time.Hour)
+		err := r.SetKey(ctx, podName, output, nodeIdExpiration)
 		if err != nil {
 			log.Error("Error adding the node to redis: ", err)
 			return err
@@ -50,3 +53,51 @@ func CheckIfNodeExistsInDB(
 
 	return nodeName, err
 }
+
+// SetNodeIdLocked stores the values in redis only while the lock of the node is still held.
+func SetNodeIdLocked(
+	lock *Lock,
+	podName string,
+	r *RedisClient,
+	ctx context.Context,
+	output string,
+) error {
+	nodeName, err := CheckIfNodeExistsInDB(r, ctx, podName)
+	if err != nil {
+		return err
+	}
+
+	// if the node is already in the db, we keep the existing value
+	if nodeName != "" {
+		log.Info("Node ", "["+podName+"]"+" found in Redis")
+		return nil
+	}
+
+	log.Info("Node ", "["+podName+"]"+" not found in Redis, let's add it - fencing token: [", lock.Fence, "]")
+	err = lock.SetKey(ctx, podName, output, nodeIdExpiration)
+	if err != nil {
+		log.Error("Error adding the node to redis: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteNode removes the id, the metadata and the pending retries of the node, it is used when the pod goes away.
+func DeleteNode(
+	r *RedisClient,
+	ctx context.Context,
+	nodeName string,
+) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, nodeName, NodeMetadataKey(nodeName))
+	pipe.ZRem(ctx, retryQueueKey, nodeName)
+	pipe.HDel(ctx, retryTasksKey, nodeName)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("Error deleting the node: [", nodeName, "]: ", err)
+		return err
+	}
+
+	log.Info("Node [", nodeName, "] deleted from Redis")
+	return nil
+}
@@ -0,0 +1,40 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jrmanes/torch/config"
+)
+
+func TestDeleteNode(t *testing.T) {
+	red, _ := newTestClient(t)
+	ctx := context.Background()
+
+	if err := red.SetKey(ctx, "da-full-1-1", "id-1", time.Hour); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	if err := red.SetHash(ctx, NodeMetadataKey("da-full-1-1"), map[string]string{"namespace": "default"}); err != nil {
+		t.Fatalf("SetHash() error = %v", err)
+	}
+	task := RetryTask{Peer: config.Peer{NodeName: "da-full-1-1"}, NextAttempt: time.Now()}
+	if err := red.EnqueueRetry(ctx, task); err != nil {
+		t.Fatalf("EnqueueRetry() error = %v", err)
+	}
+
+	if err := DeleteNode(red, ctx, "da-full-1-1"); err != nil {
+		t.Fatalf("DeleteNode() error = %v", err)
+	}
+
+	// Case 1: the id, the metadata and the retries of the node are removed.
+	if id, _ := red.GetKey(ctx, "da-full-1-1"); id != "" {
+		t.Errorf("GetKey() = %v, want empty", id)
+	}
+	if metadata, _ := red.GetHash(ctx, NodeMetadataKey("da-full-1-1")); len(metadata) != 0 {
+		t.Errorf("GetHash() = %v, want empty", metadata)
+	}
+	if tasks, _ := red.ListRetries(ctx); len(tasks) != 0 {
+		t.Errorf("ListRetries() = %v, want empty", tasks)
+	}
+}
@@ -1,23 +1,51 @@
 package redis
 
 import (
-	"github.com/adjust/rmq/v5"
+	"encoding/json"
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 )
 
-// Producer add data into the queue.
-func Producer(data, queueName string) error {
-	log.Info("Adding STS [", data, "] node to the queue: [", queueName, "]")
-	data += "-0" // we add the suffix as the pods have it in their name when we use a StatefulSet.
-	log.Info("Getting the pod from the STS [", data, "]")
-
-	connection, err := rmq.OpenConnection(
-		"producer",
-		"tcp",
-		GetRedisFullURL(),
-		2,
-		nil,
-	)
+// NodeTask represents a node that has to be processed by the consumers of the queue.
+type NodeTask struct {
+	NodeName        string   `json:"node_name"`                  // NodeName name of the pod.
+	Namespace       string   `json:"namespace,omitempty"`        // Namespace of the pod.
+	NodeType        string   `json:"node_type,omitempty"`        // NodeType type of the node, da or consensus.
+	ResourceVersion string   `json:"resource_version,omitempty"` // ResourceVersion of the resource that triggered the task.
+	Reason          string   `json:"reason,omitempty"`           // Reason why the task was generated.
+	ContainerName   string   `json:"container_name,omitempty"`   // ContainerName main container of the node, declared by the pod.
+	ConnectsTo      []string `json:"connects_to,omitempty"`      // ConnectsTo nodes that the node connects to, declared by the pod.
+	Cluster         string   `json:"cluster,omitempty"`          // Cluster where the pod runs, empty for the local one.
+	Injected        bool     `json:"injected,omitempty"`         // Injected true when the webhook injected the connections into the pod.
+	Legacy          bool     `json:"-"`                          // Legacy true when the payload was a bare pod name.
+}
+
+// ParseNodeTask decodes the payload of a delivery, the payloads that are not JSON are handled as a bare pod name,
+// as they were published by previous versions of Torch, and they are marked as Legacy so the consumer fills their type.
+func ParseNodeTask(payload string) (NodeTask, error) {
+	task := NodeTask{}
+	if !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		task.NodeName = strings.TrimSpace(payload)
+		task.Legacy = true
+		return task, nil
+	}
+
+	err := json.Unmarshal([]byte(payload), &task)
+	return task, err
+}
+
+// Producer add the task into the queue.
+func Producer(task NodeTask, queueName string) error {
+	log.Info("Adding node [", task.NodeName, "] to the queue: [", queueName, "] - reason: [", task.Reason, "]")
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		log.Error("Error: ", err)
+		return err
+	}
+
+	connection, err := GetQueueConnection()
 	if err != nil {
 		log.Error("Error: ", err)
 		return err
@@ -29,7 +57,7 @@ func Producer(data, queueName string) error {
 		return err
 	}
 
-	if err := queue.Publish(data); err != nil {
+	if err := queue.PublishBytes(data); err != nil {
 		log.Error("Error, failed to publish: ", err)
 		return err
 	}
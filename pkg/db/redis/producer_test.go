@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNodeTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    NodeTask
+		wantErr bool
+	}{
+		{
+			name:    "Case 1: JSON payload",
+			payload: `{"node_name":"consensus-full-1-0","namespace":"celestia","node_type":"consensus","resource_version":"123","reason":"StatefulSetReady"}`,
+			want: NodeTask{
+				NodeName:        "consensus-full-1-0",
+				Namespace:       "celestia",
+				NodeType:        "consensus",
+				ResourceVersion: "123",
+				Reason:          "StatefulSetReady",
+			},
+		},
+		{
+			name:    "Case 2: Bare pod name from previous versions",
+			payload: "da-bridge-1-0",
+			want:    NodeTask{NodeName: "da-bridge-1-0", Legacy: true},
+		},
+		{
+			name:    "Case 3: Invalid JSON payload",
+			payload: `{"node_name":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNodeTask(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseNodeTask() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNodeTask() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adjust/rmq/v5"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const queueDB = 2 // queueDB Redis DB used by the queues.
+
+// ErrQueueNotFound is returned when the queue doesn't exist.
+var ErrQueueNotFound = errors.New("queue not found")
+
+var (
+	queueConnOnce sync.Once      // queueConnOnce makes sure that we only open the shared connection once.
+	queueConn     rmq.Connection // queueConn connection shared by the producers and the queue administration.
+	queueConnErr  error          // queueConnErr error returned when the shared connection was opened.
+)
+
+// QueueStats represents the number of deliveries in every state of a queue.
+type QueueStats struct {
+	Name        string `json:"name"`        // Name of the queue.
+	Ready       int64  `json:"ready"`       // Ready deliveries waiting to be consumed.
+	Unacked     int64  `json:"unacked"`     // Unacked deliveries being consumed.
+	Rejected    int64  `json:"rejected"`    // Rejected deliveries that failed.
+	Consumers   int64  `json:"consumers"`   // Consumers number of consumers of the queue.
+	Connections int64  `json:"connections"` // Connections number of connections consuming the queue.
+}
+
+// OpenQueueConnection opens a new connection to the queues, the errors of the connection are sent to errChan.
+func OpenQueueConnection(tag string, errChan chan<- error) (rmq.Connection, error) {
+	return rmq.OpenConnectionWithRedisOptions(
+		tag,
+		&redis.Options{
+			Addr:     GetRedisHost() + ":" + GetRedisPort(),
+			Password: GetRedisPass(),
+			DB:       queueDB,
+		},
+		errChan,
+	)
+}
+
+// GetQueueConnection returns the connection shared by the producers and the queue administration.
+func GetQueueConnection() (rmq.Connection, error) {
+	queueConnOnce.Do(func() {
+		queueConn, queueConnErr = OpenQueueConnection("torch", nil)
+		if queueConnErr != nil {
+			log.Error("Error opening the connection to the queues: ", queueConnErr)
+		}
+	})
+	return queueConn, queueConnErr
+}
+
+// GetQueuesStats returns the stats of all the queues.
+func GetQueuesStats() ([]QueueStats, error) {
+	connection, err := GetQueueConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	queues, err := connection.GetOpenQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := connection.CollectStats(queues)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]QueueStats, 0, len(stats.QueueStats))
+	for name, stat := range stats.QueueStats {
+		result = append(result, QueueStats{
+			Name:        name,
+			Ready:       stat.ReadyCount,
+			Unacked:     stat.UnackedCount(),
+			Rejected:    stat.RejectedCount,
+			Consumers:   stat.ConsumerCount(),
+			Connections: stat.ConnectionCount(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// RequeueRejected moves all the rejected deliveries of the queue back to the ready list.
+func RequeueRejected(queueName string) (int64, error) {
+	queue, err := openExistingQueue(queueName)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := queue.ReturnRejected(math.MaxInt64)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Info("Requeued [", count, "] rejected deliveries in the queue: [", queueName, "]")
+	return count, nil
+}
+
+// PurgeRejected removes all the rejected deliveries of the queue.
+func PurgeRejected(queueName string) (int64, error) {
+	queue, err := openExistingQueue(queueName)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := queue.PurgeRejected()
+	if err != nil {
+		return 0, err
+	}
+
+	log.Info("Purged [", count, "] rejected deliveries in the queue: [", queueName, "]")
+	return count, nil
+}
+
+// RunQueueCleaner returns the unacked deliveries of the consumers that are not alive anymore to the ready list,
+// it runs every interval until the context is done.
+func RunQueueCleaner(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		connection, err := GetQueueConnection()
+		if err != nil {
+			return err
+		}
+
+		returned, err := rmq.NewCleaner(connection).Clean()
+		if err != nil {
+			log.Error("Error cleaning the queues: ", err)
+			continue
+		}
+		if returned > 0 {
+			log.Info("Recovered [", returned, "] deliveries from dead consumers")
+		}
+	}
+}
+
+// openExistingQueue opens the queue only if it already exists, so we don't create queues by mistake.
+func openExistingQueue(queueName string) (rmq.Queue, error) {
+	connection, err := GetQueueConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	queues, err := connection.GetOpenQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range queues {
+		if q == queueName {
+			return connection.OpenQueue(queueName)
+		}
+	}
+
+	return nil, ErrQueueNotFound
+}
@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// reconcileStatusKey hash with the status of the last reconciliation of every node.
+const reconcileStatusKey = internalKeyPrefix + "reconcile::status"
+
+// ReconcileStatus represents the result of the last reconciliation of a node.
+type ReconcileStatus struct {
+	NodeName     string    `json:"node_name"`         // NodeName name of the node.
+	State        string    `json:"state"`             // State result of the reconciliation: in_sync, updated or failed.
+	File         string    `json:"file,omitempty"`    // File path of the file reconciled.
+	Desired      string    `json:"desired,omitempty"` // Desired content of the file.
+	Actual       string    `json:"actual,omitempty"`  // Actual content of the file before the reconciliation.
+	Error        string    `json:"error,omitempty"`   // Error of the reconciliation.
+	ReconciledAt time.Time `json:"reconciled_at"`     // ReconciledAt time of the reconciliation.
+}
+
+// SetReconcileStatus stores the status of the last reconciliation of the node.
+func (r *RedisClient) SetReconcileStatus(ctx context.Context, status ReconcileStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(ctx, reconcileStatusKey, status.NodeName, data).Err()
+}
+
+// GetReconcileStatus returns the status of the last reconciliation of the node, ErrTaskNotFound if it wasn't
+// reconciled yet.
+func (r *RedisClient) GetReconcileStatus(ctx context.Context, nodeName string) (ReconcileStatus, error) {
+	status := ReconcileStatus{}
+	data, err := r.client.HGet(ctx, reconcileStatusKey, nodeName).Result()
+	if err == redis.Nil {
+		return status, ErrTaskNotFound
+	}
+	if err != nil {
+		return status, err
+	}
+
+	err = json.Unmarshal([]byte(data), &status)
+	return status, err
+}
+
+// ListReconcileStatus returns the status of the last reconciliation of all the nodes sorted by node name.
+func (r *RedisClient) ListReconcileStatus(ctx context.Context) ([]ReconcileStatus, error) {
+	result, err := r.client.HGetAll(ctx, reconcileStatusKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ReconcileStatus, 0, len(result))
+	for node, data := range result {
+		status := ReconcileStatus{}
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			log.Error("Error decoding the reconcile status of the node: [", node, "]: ", err)
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeName < statuses[j].NodeName
+	})
+	return statuses, nil
+}
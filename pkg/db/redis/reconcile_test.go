@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReconcileStatus(t *testing.T) {
+	red, _ := newTestClient(t)
+	ctx := context.Background()
+
+	// Case 1: the nodes not reconciled yet are not found.
+	if _, err := red.GetReconcileStatus(ctx, "da-full-1-0"); err != ErrTaskNotFound {
+		t.Fatalf("GetReconcileStatus() error = %v, want %v", err, ErrTaskNotFound)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	statuses := []ReconcileStatus{
+		{NodeName: "da-full-1-0", State: "updated", File: "/tmp/TP-ADDR", Desired: "a", Actual: "b", ReconciledAt: now},
+		{NodeName: "da-bridge-1-0", State: "in_sync", ReconciledAt: now},
+	}
+	for _, status := range statuses {
+		if err := red.SetReconcileStatus(ctx, status); err != nil {
+			t.Fatalf("SetReconcileStatus() error = %v", err)
+		}
+	}
+
+	// Case 2: the last status of the node is returned.
+	got, err := red.GetReconcileStatus(ctx, "da-full-1-0")
+	if err != nil {
+		t.Fatalf("GetReconcileStatus() error = %v", err)
+	}
+	if got.State != "updated" || got.Desired != "a" || got.Actual != "b" || !got.ReconciledAt.Equal(now) {
+		t.Errorf("GetReconcileStatus() = %+v, want %+v", got, statuses[0])
+	}
+
+	// Case 3: all the statuses are listed sorted by node name.
+	list, err := red.ListReconcileStatus(ctx)
+	if err != nil {
+		t.Fatalf("ListReconcileStatus() error = %v", err)
+	}
+	if len(list) != 2 || list[0].NodeName != "da-bridge-1-0" || list[1].NodeName != "da-full-1-0" {
+		t.Errorf("ListReconcileStatus() = %+v", list)
+	}
+}
@@ -2,12 +2,18 @@ package redis
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	internalKeyPrefix = "torch::" // internalKeyPrefix prefix of the keys that Torch uses internally, they are not nodes.
+	rmqKeyPrefix      = "rmq::"   // rmqKeyPrefix prefix of the keys that rmq uses to manage the queues.
+)
+
 type RedisClient struct {
 	client *redis.Client
 }
@@ -47,6 +53,9 @@ func (r *RedisClient) GetAllKeys(ctx context.Context) (map[string]string, error)
 		log.Error("Error getting the key ", err)
 	}
 	for _, s := range iter {
+		if IsInternalKey(s) {
+			continue
+		}
 		value, err := r.GetKey(ctx, s)
 		if err != nil {
 			log.Error("Error getting the key ", s, ": ", err)
@@ -62,3 +71,45 @@ func (r *RedisClient) GetAllKeys(ctx context.Context) (map[string]string, error)
 func (r *RedisClient) SetKeyExpiration(ctx context.Context, key string, expiration time.Duration) error {
 	return r.client.Expire(ctx, key, expiration).Err()
 }
+
+// DeleteKey removes the key from the DB.
+func (r *RedisClient) DeleteKey(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// GetHash returns all the fields of the hash stored in the key.
+func (r *RedisClient) GetHash(ctx context.Context, key string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, key).Result()
+}
+
+// SetHash replaces the hash stored in the key with the fields received.
+func (r *RedisClient) SetHash(ctx context.Context, key string, fields map[string]string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(fields) > 0 {
+		pipe.HSet(ctx, key, fields)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetHashField stores the value in the field of the hash, the rest of the fields are kept.
+func (r *RedisClient) SetHashField(ctx context.Context, key, field, value string) error {
+	return r.client.HSet(ctx, key, field, value).Err()
+}
+
+// GetHashField returns the value of the field of the hash, empty if it doesn't exist.
+func (r *RedisClient) GetHashField(ctx context.Context, key, field string) (string, error) {
+	result, err := r.client.HGet(ctx, key, field).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// IsInternalKey checks if the key is used internally by Torch or by the queues instead of storing a node.
+func IsInternalKey(key string) bool {
+	return strings.HasPrefix(key, internalKeyPrefix) || strings.HasPrefix(key, rmqKeyPrefix)
+}
@@ -0,0 +1,284 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+)
+
+const (
+	retryQueueKey = internalKeyPrefix + "retry::queue" // retryQueueKey sorted set with the nodes to retry, the score is the time to retry them.
+	retryTasksKey = internalKeyPrefix + "retry::tasks" // retryTasksKey hash with the tasks of the nodes in the retry queue.
+	deadLetterKey = internalKeyPrefix + "retry::dead"  // deadLetterKey hash with the tasks that reached the max number of retries.
+
+	retryTxAttempts = 5 // retryTxAttempts max attempts of the transactions that modify the stored tasks.
+)
+
+var (
+	// ErrTaskNotFound is returned when the task of the node is not in the queue.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskDeadLettered is returned when the node is in the dead letter set, it has to be replayed to retry it.
+	ErrTaskDeadLettered = errors.New("task in the dead letter set")
+)
+
+// claimScript returns the tasks that are due and postpones them for the visibility timeout, so if Torch stops while
+// processing them, they will be processed again.
+var claimScript = redis.NewScript(`
+local nodes = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+local tasks = {}
+for _, node in ipairs(nodes) do
+	redis.call("ZADD", KEYS[1], ARGV[3], node)
+	local task = redis.call("HGET", KEYS[2], node)
+	if task then
+		table.insert(tasks, task)
+	else
+		redis.call("ZREM", KEYS[1], node)
+	end
+end
+return tasks`)
+
+// addRetryScript adds the task to the retry queue only when the node is not queued yet, so adding it again doesn't
+// reset its attempts nor its next attempt. The nodes in the dead letter set are not added, it returns -1 for them, 1
+// when the task is added and 0 when the node was already queued.
+var addRetryScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[3], ARGV[1]) == 1 then
+	return -1
+end
+if redis.call("HSETNX", KEYS[2], ARGV[1], ARGV[2]) == 1 then
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+	return 1
+end
+redis.call("ZADD", KEYS[1], "NX", ARGV[3], ARGV[1])
+return 0`)
+
+// RetryTask represents a node that Torch has to process again.
+type RetryTask struct {
+	Peer        config.Peer `json:"peer"`                 // Peer node to process.
+	Setup       bool        `json:"setup,omitempty"`      // Setup true when the connections of the node have to be written.
+	Attempt     int         `json:"attempt"`              // Attempt number of attempts already done.
+	LastError   string      `json:"last_error,omitempty"` // LastError error of the last attempt.
+	NextAttempt time.Time   `json:"next_attempt"`         // NextAttempt time when the node will be processed.
+	UpdatedAt   time.Time   `json:"updated_at"`           // UpdatedAt time when the task was modified.
+}
+
+// EnqueueRetry adds the task to the retry queue to be processed at the time specified in NextAttempt.
+// if the node is already in the queue, the task is replaced.
+func (r *RedisClient) EnqueueRetry(ctx context.Context, task RetryTask) error {
+	task.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, retryTasksKey, task.Peer.NodeName, data)
+	pipe.ZAdd(ctx, retryQueueKey, redis.Z{
+		Score:  float64(task.NextAttempt.UnixMilli()),
+		Member: task.Peer.NodeName,
+	})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// AddRetry adds the task to the retry queue if the node is not queued yet, otherwise the stored task is kept with its
+// attempts and its next attempt, only adding the setup of the node when the task requires it, and it returns false.
+// The nodes in the dead letter set return ErrTaskDeadLettered, they are only retried again when they are replayed.
+func (r *RedisClient) AddRetry(ctx context.Context, task RetryTask) (bool, error) {
+	task.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return false, err
+	}
+
+	added, err := addRetryScript.Run(
+		ctx,
+		r.client,
+		[]string{retryQueueKey, retryTasksKey, deadLetterKey},
+		task.Peer.NodeName,
+		data,
+		strconv.FormatInt(task.NextAttempt.UnixMilli(), 10),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	if added < 0 {
+		return false, ErrTaskDeadLettered
+	}
+	if added == 0 && task.Setup {
+		return false, r.setRetrySetup(ctx, task.Peer.NodeName)
+	}
+	return added == 1, nil
+}
+
+// setRetrySetup marks the stored task of the node as requiring its setup, the task is decoded and encoded again in a
+// transaction, which is retried when the tasks change in the meantime. It does nothing if the node is not queued.
+func (r *RedisClient) setRetrySetup(ctx context.Context, nodeName string) error {
+	var err error
+	for i := 0; i < retryTxAttempts; i++ {
+		if err = r.client.Watch(ctx, setRetrySetupTx(ctx, nodeName), retryTasksKey); err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+// setRetrySetupTx returns the transaction that marks the stored task of the node as requiring its setup.
+func setRetrySetupTx(ctx context.Context, nodeName string) func(tx *redis.Tx) error {
+	return func(tx *redis.Tx) error {
+		data, err := tx.HGet(ctx, retryTasksKey, nodeName).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		task := RetryTask{}
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return err
+		}
+		if task.Setup {
+			return nil
+		}
+		task.Setup = true
+		task.UpdatedAt = time.Now().UTC()
+		updated, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, retryTasksKey, nodeName, updated)
+			return nil
+		})
+		return err
+	}
+}
+
+// ClaimDueRetries returns up to limit tasks that are due, they stay in the queue until they are completed,
+// rescheduled or moved to the dead letter set, if not, they are processed again after the visibility timeout.
+func (r *RedisClient) ClaimDueRetries(
+	ctx context.Context,
+	now time.Time,
+	limit int,
+	visibilityTimeout time.Duration,
+) ([]RetryTask, error) {
+	result, err := claimScript.Run(
+		ctx,
+		r.client,
+		[]string{retryQueueKey, retryTasksKey},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		limit,
+		strconv.FormatInt(now.Add(visibilityTimeout).UnixMilli(), 10),
+	).StringSlice()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	return decodeTasks(result), nil
+}
+
+// CompleteRetry removes the task of the node from the retry queue.
+func (r *RedisClient) CompleteRetry(ctx context.Context, nodeName string) error {
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, retryQueueKey, nodeName)
+	pipe.HDel(ctx, retryTasksKey, nodeName)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetter moves the task from the retry queue to the dead letter set.
+func (r *RedisClient) DeadLetter(ctx context.Context, task RetryTask) error {
+	task.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, retryQueueKey, task.Peer.NodeName)
+	pipe.HDel(ctx, retryTasksKey, task.Peer.NodeName)
+	pipe.HSet(ctx, deadLetterKey, task.Peer.NodeName, data)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListRetries returns the tasks pending in the retry queue.
+func (r *RedisClient) ListRetries(ctx context.Context) ([]RetryTask, error) {
+	return r.listTasks(ctx, retryTasksKey)
+}
+
+// ListDeadLetters returns the tasks in the dead letter set.
+func (r *RedisClient) ListDeadLetters(ctx context.Context) ([]RetryTask, error) {
+	return r.listTasks(ctx, deadLetterKey)
+}
+
+// ReplayDeadLetter moves the task of the node from the dead letter set back to the retry queue, resetting the attempts.
+func (r *RedisClient) ReplayDeadLetter(ctx context.Context, nodeName string) (RetryTask, error) {
+	data, err := r.client.HGet(ctx, deadLetterKey, nodeName).Result()
+	if err == redis.Nil {
+		return RetryTask{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return RetryTask{}, err
+	}
+
+	task := RetryTask{}
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		return RetryTask{}, err
+	}
+	task.Attempt = 0
+	task.LastError = ""
+	task.NextAttempt = time.Now().UTC()
+
+	if err := r.EnqueueRetry(ctx, task); err != nil {
+		return RetryTask{}, err
+	}
+	if err := r.client.HDel(ctx, deadLetterKey, nodeName).Err(); err != nil {
+		return RetryTask{}, err
+	}
+
+	log.Info("Node [", nodeName, "] replayed from the dead letter set")
+	return task, nil
+}
+
+// listTasks returns the tasks stored in the hash sorted by node name.
+func (r *RedisClient) listTasks(ctx context.Context, key string) ([]RetryTask, error) {
+	result, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]string, 0, len(result))
+	for _, v := range result {
+		data = append(data, v)
+	}
+
+	tasks := decodeTasks(data)
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Peer.NodeName < tasks[j].Peer.NodeName
+	})
+	return tasks, nil
+}
+
+// decodeTasks unmarshals the tasks, skipping the ones that are not valid.
+func decodeTasks(data []string) []RetryTask {
+	tasks := make([]RetryTask, 0, len(data))
+	for _, d := range data {
+		task := RetryTask{}
+		if err := json.Unmarshal([]byte(d), &task); err != nil {
+			log.Error("Error decoding the task: [", d, "]: ", err)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
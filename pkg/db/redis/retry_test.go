@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrmanes/torch/config"
+)
+
+func TestRetryQueue(t *testing.T) {
+	red, _ := newTestClient(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	due := RetryTask{Peer: config.Peer{NodeName: "da-full-1-0"}, NextAttempt: now.Add(-time.Second)}
+	later := RetryTask{Peer: config.Peer{NodeName: "da-full-2-0"}, NextAttempt: now.Add(time.Hour)}
+	for _, task := range []RetryTask{due, later} {
+		if err := red.EnqueueRetry(ctx, task); err != nil {
+			t.Fatalf("EnqueueRetry() error = %v", err)
+		}
+	}
+
+	// Case 1: only the tasks that are due are claimed.
+	tasks, err := red.ClaimDueRetries(ctx, now, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimDueRetries() error = %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Peer.NodeName != "da-full-1-0" {
+		t.Fatalf("ClaimDueRetries() = %v, want [da-full-1-0]", tasks)
+	}
+
+	// Case 2: a claimed task is not claimed again until the visibility timeout expires.
+	tasks, err = red.ClaimDueRetries(ctx, now, 10, time.Minute)
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("ClaimDueRetries() = %v, %v, want no tasks", tasks, err)
+	}
+	tasks, err = red.ClaimDueRetries(ctx, now.Add(2*time.Minute), 10, time.Minute)
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("ClaimDueRetries() = %v, %v, want one task", tasks, err)
+	}
+
+	// Case 3: the task moved to the dead letter set can be replayed.
+	due.Attempt = 5
+	due.LastError = "error"
+	if err := red.DeadLetter(ctx, due); err != nil {
+		t.Fatalf("DeadLetter() error = %v", err)
+	}
+	dead, err := red.ListDeadLetters(ctx)
+	if err != nil || len(dead) != 1 || dead[0].Attempt != 5 {
+		t.Errorf("ListDeadLetters() = %v, %v, want one task with 5 attempts", dead, err)
+	}
+	pending, err := red.ListRetries(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Peer.NodeName != "da-full-2-0" {
+		t.Errorf("ListRetries() = %v, %v, want [da-full-2-0]", pending, err)
+	}
+
+	replayed, err := red.ReplayDeadLetter(ctx, "da-full-1-0")
+	if err != nil || replayed.Attempt != 0 || replayed.LastError != "" {
+		t.Errorf("ReplayDeadLetter() = %v, %v, want a task without attempts", replayed, err)
+	}
+	if _, err := red.ReplayDeadLetter(ctx, "da-full-1-0"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("ReplayDeadLetter() error = %v, want %v", err, ErrTaskNotFound)
+	}
+
+	// Case 4: completed tasks are removed from the queue.
+	if err := red.CompleteRetry(ctx, "da-full-1-0"); err != nil {
+		t.Fatalf("CompleteRetry() error = %v", err)
+	}
+	pending, err = red.ListRetries(ctx)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("ListRetries() = %v, %v, want one task", pending, err)
+	}
+}
+
+func TestAddRetry(t *testing.T) {
+	red, _ := newTestClient(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// Case 1: the node is added when it is not queued.
+	first := RetryTask{Peer: config.Peer{NodeName: "da-full-1-0", DnsConnections: []string{}}, NextAttempt: now}
+	added, err := red.AddRetry(ctx, first)
+	if err != nil || !added {
+		t.Fatalf("AddRetry() = %v, %v, want the task added", added, err)
+	}
+
+	// Case 2: adding the node again keeps its attempts and its next attempt.
+	retried := first
+	retried.Attempt = 3
+	retried.LastError = "error"
+	retried.NextAttempt = now.Add(time.Hour)
+	if err := red.EnqueueRetry(ctx, retried); err != nil {
+		t.Fatalf("EnqueueRetry() error = %v", err)
+	}
+	added, err = red.AddRetry(ctx, first)
+	if err != nil || added {
+		t.Fatalf("AddRetry() = %v, %v, want the task kept", added, err)
+	}
+	pending, err := red.ListRetries(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Attempt != 3 || pending[0].LastError != "error" {
+		t.Errorf("ListRetries() = %v, %v, want the task with 3 attempts", pending, err)
+	}
+	tasks, err := red.ClaimDueRetries(ctx, now.Add(time.Minute), 10, time.Minute)
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("ClaimDueRetries() = %v, %v, want no tasks before the next attempt", tasks, err)
+	}
+
+	// Case 3: adding the node again with its setup keeps its attempts and marks the setup.
+	setup := first
+	setup.Setup = true
+	added, err = red.AddRetry(ctx, setup)
+	if err != nil || added {
+		t.Fatalf("AddRetry() = %v, %v, want the task kept", added, err)
+	}
+	pending, err = red.ListRetries(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Attempt != 3 || !pending[0].Setup {
+		t.Errorf("ListRetries() = %v, %v, want the task with 3 attempts and its setup", pending, err)
+	}
+
+	// Case 4: the nodes in the dead letter set are not added until they are replayed.
+	if err := red.DeadLetter(ctx, retried); err != nil {
+		t.Fatalf("DeadLetter() error = %v", err)
+	}
+	if _, err := red.AddRetry(ctx, first); !errors.Is(err, ErrTaskDeadLettered) {
+		t.Errorf("AddRetry() error = %v, want %v", err, ErrTaskDeadLettered)
+	}
+	pending, err = red.ListRetries(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("ListRetries() = %v, %v, want no tasks", pending, err)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -12,12 +15,15 @@ import (
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/metrics"
 	"github.com/jrmanes/torch/pkg/nodes"
 )
 
 const (
-	errorMsg        = "Error: "        // errorMsg common error message.
-	timeoutDuration = 30 * time.Second // timeoutDuration we specify the max time to run the func.
+	errorMsg        = "Error: "              // errorMsg common error message.
+	timeoutDuration = 30 * time.Second       // timeoutDuration we specify the max time to run the func.
+	forwardedHeader = "X-Torch-Forwarded-By" // forwardedHeader header added to the requests forwarded to the leader.
 )
 
 type RequestBody struct {
@@ -172,18 +178,489 @@ func Gen(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
 	ReturnResponse(resp, w)
 }
 
+// ListExternalMultiAddrs handles the HTTP GET request to list the external multi addresses of the nodes by node name.
+func ListExternalMultiAddrs(w http.ResponseWriter) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	records, err := redis.GetNodeRecords(red, ctx)
+	if err != nil {
+		log.Error("Error getting the nodes: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	multiAddrs := map[string]string{}
+	for _, record := range records {
+		if ma := record.Metadata[nodes.ExternalMultiAddrField]; ma != "" {
+			multiAddrs[record.NodeName] = ma
+		}
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   multiAddrs,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// GetExternalMultiAddr handles the HTTP GET request to get the external multi address of a node.
+func GetExternalMultiAddr(w http.ResponseWriter, r *http.Request) {
+	nodeName := mux.Vars(r)["nodeName"]
+
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	ma, err := nodes.GetExternalMultiAddr(ctx, red, nodeName)
+	if err != nil {
+		log.Error("Error getting the external multi address of the node: [", nodeName, "]: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   nodeName,
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	if ma == "" {
+		resp := Response{
+			Status: http.StatusNotFound,
+			Body:   nodeName,
+			Errors: "[ERROR] External multi address of the node [" + nodeName + "] not found",
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   ma,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// Export handles the HTTP GET request to dump all the nodes stored in the DB as a versioned JSON backup.
+func Export(w http.ResponseWriter) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	backup, err := redis.Export(red, ctx)
+	if err != nil {
+		log.Error("Error exporting the nodes: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	// the backup is returned as it is, so it can be used directly as the body of the import.
+	jsonData, err := json.Marshal(backup)
+	if err != nil {
+		log.Error("Error marshaling to JSON:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jsonData)
+	if err != nil {
+		log.Error("Error writing response:", err)
+	}
+}
+
+// Import handles the HTTP POST request to load a backup into the DB.
+// the query params mode (merge|overwrite) and dryRun (true|false) specify how the backup is applied.
+func Import(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
+	var backup redis.Backup
+
+	mode, err := redis.ParseImportMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		log.Error(errorMsg, err)
+		resp := Response{
+			Status: http.StatusBadRequest,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	err = json.NewDecoder(r.Body).Decode(&backup)
+	if err != nil {
+		log.Error("Error decoding the request body into the struct:", err)
+		resp := Response{
+			Status: http.StatusBadRequest,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	result, err := redis.Import(red, ctx, backup, mode, dryRun)
+	if err != nil {
+		log.Error("Error importing the nodes: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   result,
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	if !dryRun {
+		RegisterImportedMetrics(cfg, backup, result)
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   result,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// RegisterImportedMetrics registers the multiaddr metric for the nodes added or updated by an import, and removes the
+// metric of the nodes deleted by it.
+func RegisterImportedMetrics(cfg config.MutualPeersConfig, backup redis.Backup, result redis.ImportResult) {
+	imported := make(map[string]bool, len(result.Changes))
+	for _, c := range result.Changes {
+		switch c.Action {
+		case redis.ActionAdd, redis.ActionUpdate:
+			imported[c.NodeName] = true
+		case redis.ActionDelete:
+			metrics.UnregisterMetric(c.NodeName)
+		}
+	}
+
+	for _, n := range backup.Nodes {
+		if !imported[n.NodeName] {
+			continue
+		}
+
+		log.Info("Node: [", n.NodeName, "], imported generating metric: ", " [", n.NodeID, "]")
+		metrics.RegisterMetric(metrics.MultiAddrs{
+			ServiceName: "torch",
+			NodeName:    n.NodeName,
+			MultiAddr:   n.NodeID,
+			Namespace:   importedNodeNamespace(cfg, n.NodeName),
+			Value:       1,
+		})
+	}
+}
+
+// importedNodeNamespace returns the namespace of the node from the config, the namespace watched in its cluster when
+// the node doesn't declare it, and the namespace of Torch when the node is not in the config.
+func importedNodeNamespace(cfg config.MutualPeersConfig, nodeName string) string {
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if peer.NodeName != nodeName {
+				continue
+			}
+			if peer.Namespace != "" {
+				return peer.Namespace
+			}
+			return k8s.ClusterNamespace(peer.Cluster)
+		}
+	}
+	return k8s.GetCurrentNamespace()
+}
+
+// ListRetries handles the HTTP GET request to list the nodes pending in the retry queue.
+func ListRetries(w http.ResponseWriter) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	tasks, err := red.ListRetries(ctx)
+	ReturnTasks(w, tasks, err)
+}
+
+// ListDeadLetters handles the HTTP GET request to list the nodes that reached the max number of retries.
+func ListDeadLetters(w http.ResponseWriter) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	tasks, err := red.ListDeadLetters(ctx)
+	ReturnTasks(w, tasks, err)
+}
+
+// ReplayDeadLetter handles the HTTP POST request to move a node from the dead letter set back to the retry queue.
+func ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	nodeName := mux.Vars(r)["nodeName"]
+
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	task, err := red.ReplayDeadLetter(ctx, nodeName)
+	if err != nil {
+		log.Error("Error replaying node: [", nodeName, "]: ", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, redis.ErrTaskNotFound) {
+			status = http.StatusNotFound
+		}
+		resp := Response{
+			Status: status,
+			Body:   nodeName,
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   task,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// ReturnTasks writes the response with the list of tasks or the error.
+func ReturnTasks(w http.ResponseWriter, tasks []redis.RetryTask, err error) {
+	if err != nil {
+		log.Error("Error getting the tasks: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   tasks,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// ListReconcileStatus handles the HTTP GET request to list the status of the last reconciliation of the nodes.
+func ListReconcileStatus(w http.ResponseWriter) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	statuses, err := red.ListReconcileStatus(ctx)
+	if err != nil {
+		log.Error("Error getting the reconcile status: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   statuses,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// GetReconcileStatus handles the HTTP GET request to get the status of the last reconciliation of a node.
+func GetReconcileStatus(w http.ResponseWriter, r *http.Request) {
+	nodeName := mux.Vars(r)["nodeName"]
+
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	status, err := red.GetReconcileStatus(ctx, nodeName)
+	if err != nil {
+		log.Error("Error getting the reconcile status of the node: [", nodeName, "]: ", err)
+		code := http.StatusInternalServerError
+		if errors.Is(err, redis.ErrTaskNotFound) {
+			code = http.StatusNotFound
+		}
+		resp := Response{
+			Status: code,
+			Body:   nodeName,
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   status,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// Reconcile handles the HTTP POST request to reconcile a node of the config without waiting for the next period.
+func Reconcile(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
+	nodeName := mux.Vars(r)["nodeName"]
+
+	ok, peer := nodes.ValidateNode(nodeName, cfg)
+	if !ok {
+		log.Error("Pod doesn't exists in the config: [", nodeName, "]")
+		resp := Response{
+			Status: http.StatusNotFound,
+			Body:   nodeName,
+			Errors: "Pod doesn't exists in the config",
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	status, reconciled := nodes.ReconcilePeer(peer)
+	if !reconciled {
+		resp := Response{
+			Status: http.StatusBadRequest,
+			Body:   nodeName,
+			Errors: "Torch doesn't write any file in the node",
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	code := http.StatusOK
+	if status.State == nodes.ReconcileFailed {
+		code = http.StatusInternalServerError
+	}
+	resp := Response{
+		Status: code,
+		Body:   status,
+		Errors: status.Error,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// ListQueues handles the HTTP GET request to get the stats of the queues.
+func ListQueues(w http.ResponseWriter) {
+	stats, err := redis.GetQueuesStats()
+	if err != nil {
+		log.Error("Error getting the stats of the queues: ", err)
+		resp := Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   stats,
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// RequeueRejected handles the HTTP POST request to move the rejected deliveries of a queue back to the ready list.
+func RequeueRejected(w http.ResponseWriter, r *http.Request) {
+	ReturnQueueOperation(w, r, redis.RequeueRejected)
+}
+
+// PurgeRejected handles the HTTP DELETE request to remove the rejected deliveries of a queue.
+func PurgeRejected(w http.ResponseWriter, r *http.Request) {
+	ReturnQueueOperation(w, r, redis.PurgeRejected)
+}
+
+// ReturnQueueOperation runs the operation against the queue of the request and writes the number of deliveries affected.
+func ReturnQueueOperation(w http.ResponseWriter, r *http.Request, operation func(queueName string) (int64, error)) {
+	queueName := mux.Vars(r)["name"]
+
+	count, err := operation(queueName)
+	if err != nil {
+		log.Error("Error in the queue: [", queueName, "]: ", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, redis.ErrQueueNotFound) {
+			status = http.StatusNotFound
+		}
+		resp := Response{
+			Status: status,
+			Body:   queueName,
+			Errors: err.Error(),
+		}
+		ReturnResponse(resp, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   map[string]int64{queueName: count},
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
 func ConfigureNode(
 	cfg config.MutualPeersConfig,
 	peer config.Peer,
 	err error,
 ) Response {
 	// Get the default values in case we need
-	switch peer.NodeType {
-	case "da":
-		peer = nodes.SetDaNodeDefault(peer)
-	case "consensus":
-		peer = nodes.SetConsNodeDefault(peer)
-	}
+	peer = nodes.SetNodeDefault(peer)
 
 	// check if the node uses env var
 	if peer.ConnectsAsEnvVar {
@@ -221,6 +698,38 @@ func ConfigureNode(
 	}
 }
 
+// Readyz handles the readiness probe, Torch is ready once the RBAC self-check confirms that it has all the permissions
+// that it needs. Unlike the rest of the API, the status code of the response is the real one, so the probe fails with
+// a 503 that includes the missing permissions.
+func Readyz(w http.ResponseWriter) {
+	status := k8s.GetRBACStatus()
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   status,
+	}
+	if !status.Ready() {
+		resp.Status = http.StatusServiceUnavailable
+		resp.Errors = "missing RBAC permissions, run `torch doctor rbac` to generate the Role that Torch needs"
+		if !status.Checked {
+			resp.Errors = "the RBAC permissions are not checked yet"
+		}
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		log.Error("Error marshaling to JSON:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	_, err = w.Write(jsonData)
+	if err != nil {
+		log.Error("Error writing response:", err)
+	}
+}
+
 // ReturnResponse assert function to write the response.
 func ReturnResponse(resp Response, w http.ResponseWriter) {
 	jsonData, err := json.Marshal(resp)
@@ -246,3 +755,37 @@ func LogRequest(handler http.Handler) http.Handler {
 		handler.ServeHTTP(w, r)
 	})
 }
+
+// LeaderOnly is a middleware function that only serves the request in the leader replica, the followers forward it
+// to the leader when its address is known, otherwise they reject it.
+func LeaderOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if k8s.IsLeader() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		leaderAddr := k8s.GetLeaderAddress()
+		// avoid loops in case the leader changed while the request was being forwarded.
+		if leaderAddr == "" || r.Header.Get(forwardedHeader) != "" {
+			log.Warn("Rejecting request, this replica is not the leader: ", r.Method, " ", r.URL.Path)
+			w.Header().Set("Retry-After", "5")
+			resp := Response{
+				Status: http.StatusServiceUnavailable,
+				Body:   k8s.GetLeader(),
+				Errors: "this replica is not the leader, retry the request against the leader",
+			}
+			ReturnResponse(resp, w)
+			return
+		}
+
+		target := &url.URL{
+			Scheme: "http",
+			Host:   net.JoinHostPort(leaderAddr, GetHttpPort()),
+		}
+		log.Info("Forwarding request to the leader: [", target.Host, "] ", r.Method, " ", r.URL.Path)
+
+		r.Header.Set(forwardedHeader, k8s.GetIdentity())
+		httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+	}
+}
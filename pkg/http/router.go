@@ -29,13 +29,67 @@ func Router(r *mux.Router, cfg config.MutualPeersConfig) *mux.Router {
 		GetNoId(w, r, cfg)
 	}).Methods("GET")
 
+	// external multi addresses of the nodes exposed through Load Balancers
+	s.HandleFunc("/external", func(w http.ResponseWriter, r *http.Request) {
+		ListExternalMultiAddrs(w)
+	}).Methods("GET")
+	s.HandleFunc("/external/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
+		GetExternalMultiAddr(w, r)
+	}).Methods("GET")
+
 	// generate
-	s.HandleFunc("/gen", func(w http.ResponseWriter, r *http.Request) {
+	s.HandleFunc("/gen", LeaderOnly(func(w http.ResponseWriter, r *http.Request) {
 		Gen(w, r, cfg)
-	}).Methods("POST")
+	})).Methods("POST")
+
+	// backup & restore the nodes stored in the DB
+	s.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+		Export(w)
+	}).Methods("GET")
+	s.HandleFunc("/import", LeaderOnly(func(w http.ResponseWriter, r *http.Request) {
+		Import(w, r, cfg)
+	})).Methods("POST")
+
+	// retry queue
+	s.HandleFunc("/retries", func(w http.ResponseWriter, r *http.Request) {
+		ListRetries(w)
+	}).Methods("GET")
+	s.HandleFunc("/retries/dead", func(w http.ResponseWriter, r *http.Request) {
+		ListDeadLetters(w)
+	}).Methods("GET")
+	s.HandleFunc("/retries/dead/{nodeName}/replay", LeaderOnly(func(w http.ResponseWriter, r *http.Request) {
+		ReplayDeadLetter(w, r)
+	})).Methods("POST")
+
+	// rmq queues
+	s.HandleFunc("/queues", func(w http.ResponseWriter, r *http.Request) {
+		ListQueues(w)
+	}).Methods("GET")
+	s.HandleFunc("/queues/{name}/requeue-rejected", LeaderOnly(func(w http.ResponseWriter, r *http.Request) {
+		RequeueRejected(w, r)
+	})).Methods("POST")
+	s.HandleFunc("/queues/{name}/rejected", LeaderOnly(func(w http.ResponseWriter, r *http.Request) {
+		PurgeRejected(w, r)
+	})).Methods("DELETE")
+
+	// reconciliation of the nodes
+	s.HandleFunc("/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		ListReconcileStatus(w)
+	}).Methods("GET")
+	s.HandleFunc("/reconcile/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
+		GetReconcileStatus(w, r)
+	}).Methods("GET")
+	s.HandleFunc("/reconcile/{nodeName}", LeaderOnly(func(w http.ResponseWriter, r *http.Request) {
+		Reconcile(w, r, cfg)
+	})).Methods("POST")
 
 	// metrics
 	r.Handle("/metrics", promhttp.Handler())
 
+	// readiness probe, it fails while Torch is missing some of the RBAC permissions that it needs.
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		Readyz(w)
+	}).Methods("GET")
+
 	return r
 }
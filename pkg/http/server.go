@@ -18,11 +18,15 @@ import (
 	"github.com/jrmanes/torch/pkg/k8s"
 	"github.com/jrmanes/torch/pkg/metrics"
 	"github.com/jrmanes/torch/pkg/nodes"
+	"github.com/jrmanes/torch/pkg/supervisor"
+	"github.com/jrmanes/torch/pkg/webhook"
 )
 
 const (
 	retryInterval        = 10 * time.Second // retryInterval Retry interval in seconds to generate the consensus metric.
 	hashMetricGenTimeout = 5 * time.Minute  // hashMetricGenTimeout specify the max time to retry to generate the metric.
+	queueCleanerInterval = 1 * time.Minute  // queueCleanerInterval how often Torch recovers the deliveries of dead consumers.
+	defaultGracePeriod   = 30 * time.Second // defaultGracePeriod max time to stop Torch when SHUTDOWN_GRACE_PERIOD is empty.
 )
 
 // GetHttpPort GetPort retrieves the namespace where the service will be deployed
@@ -43,12 +47,34 @@ func GetHttpPort() string {
 	return port
 }
 
+// GetShutdownGracePeriod returns the max time that Torch waits for the workers, the remote commands and the HTTP
+// requests to finish when it stops, it can be changed with SHUTDOWN_GRACE_PERIOD.
+func GetShutdownGracePeriod() time.Duration {
+	gracePeriod := os.Getenv("SHUTDOWN_GRACE_PERIOD")
+	if gracePeriod == "" {
+		return defaultGracePeriod
+	}
+
+	d, err := time.ParseDuration(gracePeriod)
+	if err != nil || d <= 0 {
+		log.Error("Invalid SHUTDOWN_GRACE_PERIOD [", gracePeriod, "], using default grace period ", defaultGracePeriod)
+		return defaultGracePeriod
+	}
+
+	return d
+}
+
 // Run initializes the HTTP server, registers metrics for all nodes in the configuration,
 // and starts the server.
 func Run(cfg config.MutualPeersConfig) {
 	// Get http port
 	httpPort := GetHttpPort()
 
+	// the version of the config is added to the annotations of the pods set up by Torch.
+	nodes.SetConfigVersion(cfg.Version())
+	// the peers of the config are used to find the cluster of the nodes that every peer connects to.
+	nodes.SetPeers(cfg)
+
 	// Set up the HTTP server
 	r := mux.NewRouter()
 	// Get the routers
@@ -69,8 +95,15 @@ func Run(cfg config.MutualPeersConfig) {
 		Handler: r,
 	}
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
+	// Create the root context, it is canceled when Torch receives a signal to stop.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Create the clients of the remote clusters where some of the nodes run.
+	if err := k8s.RegisterClusters(ctx, cfg.Clusters); err != nil {
+		log.Errorf("Error registering the clusters: %v", err)
+		return
+	}
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -80,90 +113,152 @@ func Run(cfg config.MutualPeersConfig) {
 	log.Info("Server Started...")
 	log.Info("Listening on port: " + httpPort)
 
-	// check if Torch has to generate the metric or not, we invoke this function async to continue the execution flow.
-	go BackgroundGenerateHashMetric(cfg)
-	go BackgroundGenerateLBMetric()
+	sup := supervisor.New(ctx)
+	// All the replicas check their permissions, the result is exposed in the readiness probe.
+	if k8s.RBACCheckEnabled() {
+		sup.Go("rbac-check", func(ctx context.Context) error {
+			return k8s.RunRBACCheck(ctx, cfg)
+		})
+	}
 
-	// Initialize the goroutine to check the nodes in the queue.
-	log.Info("Initializing queues to process the nodes...")
-	// Create a new context without timeout as we want to keep this goroutine running forever, if we specify a timeout,
-	// it will be canceled at some point.c
-	go func() {
-		go nodes.ProcessTaskQueue()
-	}()
+	// All the replicas serve the admission webhook, so the pods can be created while the leader changes.
+	if webhook.Enabled() {
+		sup.Go("webhook", func(ctx context.Context) error {
+			return webhook.Run(ctx, cfg)
+		})
+	}
 
-	log.Info("Initializing goroutine to watch over the StatefulSets...")
-	// Initialize a goroutine to watch for changes in StatefulSets in the namespace.
-	go func() {
-		// Call the WatchStatefulSets function and capture any potential error.
-		err := k8s.WatchStatefulSets()
-		if err != nil {
-			// Log an error message if WatchStatefulSets encounters an error.
-			log.Error("Error in WatchStatefulSets: ", err)
+	// Only one replica runs the watchers, the queues and the background metrics, the rest of them only serve the API.
+	if k8s.LeaderElectionEnabled() {
+		// when the leadership is lost, the workers are stopped and the replica tries to acquire the Lease again.
+		sup.Go("leader-election", func(ctx context.Context) error {
+			return k8s.RunLeaderElection(ctx, func(ctx context.Context) {
+				RunBackgroundWorkers(ctx, cfg)
+			})
+		})
+	} else {
+		sup.Go("background-workers", func(ctx context.Context) error {
+			RunBackgroundWorkers(ctx, cfg)
+			return nil
+		})
+	}
+
+	<-ctx.Done()
+	// restore the default behaviour, so a second signal stops Torch without waiting.
+	stop()
+	log.Info("Server Stopping...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), GetShutdownGracePeriod())
+	defer cancel()
+
+	Shutdown(shutdownCtx, server, sup)
+	log.Info("Server Exited Properly")
+}
+
+// Shutdown stops the HTTP server, waits for the background workers and the remote commands being executed,
+// it gives up when the context is done.
+func Shutdown(ctx context.Context, server *http.Server, sup *supervisor.Supervisor) {
+	eg := errgroup.Group{}
+	eg.Go(func() error {
+		return server.Shutdown(ctx)
+	})
+	eg.Go(func() error {
+		workersDone := make(chan error, 1)
+		go func() {
+			workersDone <- sup.Wait()
+		}()
+
+		select {
+		case err := <-workersDone:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-	}()
 
-	// Initialize the goroutine to add a watcher to the StatefulSets in the namespace.
-	log.Info("Initializing Redis consumer")
-	go func() {
-		nodes.ConsumerInit("k8s")
-	}()
+		return k8s.WaitForRemoteCommands(ctx)
+	})
 
-	// Check if we already have some multi addresses in the DB and expose them, there might be a situation where Torch
-	// get restarted, and we already have the nodes IDs, so we can expose them.
-	err = RegisterMetrics(cfg)
-	if err != nil {
-		log.Error("Couldn't generate the metrics...", err)
+	if err := eg.Wait(); err != nil {
+		log.Error("Server Shutdown Failed: ", err)
 	}
 
-	<-done
-	log.Info("Server Stopped")
+	// stop sending the events once the workers are done.
+	k8s.ShutdownEvents()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer func() {
-		cancel()
-	}()
+// RunBackgroundWorkers runs the watchers, the queues and the background metrics until the context is done.
+func RunBackgroundWorkers(ctx context.Context, cfg config.MutualPeersConfig) {
+	sup := supervisor.New(ctx)
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Errorf("Server Shutdown Failed: %v", err)
+	// check if Torch has to generate the metric or not.
+	sup.Go("hash-metric", func(ctx context.Context) error {
+		return BackgroundGenerateHashMetric(ctx, cfg)
+	})
+
+	// Initialize the worker to check the nodes in the queue.
+	log.Info("Initializing queues to process the nodes...")
+	sup.Go("task-queue", nodes.ProcessTaskQueue)
+
+	// Initialize the informers to watch for changes in the StatefulSets and the Load Balancers in the namespace.
+	log.Info("Initializing the informers to watch over the StatefulSets and the Services...")
+	sup.Go("informers", func(ctx context.Context) error {
+		return k8s.RunInformers(ctx, cfg.Discovery)
+	})
+	for _, cluster := range k8s.ClusterNames() {
+		cluster := cluster
+		sup.Go("informers/"+cluster, func(ctx context.Context) error {
+			return k8s.RunClusterInformers(ctx, cluster, cfg.Discovery)
+		})
 	}
-	log.Info("Server Exited Properly")
-}
 
-// BackgroundGenerateLBMetric initializes a goroutine to generate the load_balancer metric.
-func BackgroundGenerateLBMetric() {
-	log.Info("Initializing goroutine to generate the metric: load_balancer ")
+	// Apply the NetworkPolicies that only allow the connections of the config between the nodes.
+	if k8s.NetworkPoliciesEnabled() {
+		log.Info("Applying the NetworkPolicies of the nodes...")
+		sup.Go("network-policies", func(ctx context.Context) error {
+			return k8s.SyncNetworkPolicies(ctx, cfg)
+		})
+	}
+
+	// Initialize the consumer of the nodes sent by the watchers.
+	log.Info("Initializing Redis consumer")
+	sup.Go("consumer", func(ctx context.Context) error {
+		return nodes.ConsumerInit(ctx, "k8s", cfg)
+	})
+
+	// Initialize the controller that converges the nodes to the topology of the config.
+	sup.Go("reconciler", func(ctx context.Context) error {
+		return nodes.RunReconciler(ctx, cfg)
+	})
+
+	// Initialize the worker to recover the deliveries of the consumers that died.
+	sup.Go("queue-cleaner", func(ctx context.Context) error {
+		return redis.RunQueueCleaner(ctx, queueCleanerInterval)
+	})
 
-	// Retrieve the list of Load Balancers
-	_, err := k8s.RetrieveAndGenerateMetrics()
+	// Check if we already have some multi addresses in the DB and expose them, there might be a situation where Torch
+	// get restarted, and we already have the nodes IDs, so we can expose them.
+	err := RegisterMetrics(cfg)
 	if err != nil {
-		log.Printf("Failed to update metrics: %v", err)
+		log.Error("Couldn't generate the metrics...", err)
 	}
 
-	// Start watching for changes to the services in a separate goroutine
-	done := make(chan error)
-	go k8s.WatchServices(done)
-
-	// Handle errors from WatchServices
-	for {
-		select {
-		case err := <-done:
-			if err != nil {
-				log.Error("Error in WatchServices: ", err)
-			}
-		}
+	if err := sup.Wait(); err != nil {
+		log.Error("Error in the background workers: ", err)
 	}
+	log.Info("Background workers stopped")
 }
 
 // BackgroundGenerateHashMetric checks if the consensusNode field is defined in the config to generate the metric from the Genesis Hash data.
-func BackgroundGenerateHashMetric(cfg config.MutualPeersConfig) {
+func BackgroundGenerateHashMetric(ctx context.Context, cfg config.MutualPeersConfig) error {
 	log.Info("BackgroundGenerateHashMetric...")
 
-	if cfg.MutualPeers[0].ConsensusNode != "" {
+	if len(cfg.MutualPeers) > 0 && cfg.MutualPeers[0].ConsensusNode != "" {
 		log.Info("Initializing goroutine to generate the metric: hash ")
 
 		// Create an errgroup with a context
-		eg, ctx := errgroup.WithContext(context.Background())
+		eg, ctx := errgroup.WithContext(ctx)
 
 		// Run the WatchHashMetric function in a separate goroutine
 		eg.Go(func() error {
@@ -171,12 +266,13 @@ func BackgroundGenerateHashMetric(cfg config.MutualPeersConfig) {
 			return WatchHashMetric(cfg, ctx)
 		})
 
-		// Wait for all goroutines to finish
+		// Wait for all goroutines to finish, Torch gives up after hashMetricGenTimeout, so we don't restart it.
 		if err := eg.Wait(); err != nil {
 			log.Error("Error in BackgroundGenerateHashMetric: ", err)
-			// Handle the error as needed
 		}
 	}
+
+	return nil
 }
 
 // WatchHashMetric watches for changes to generate hash metrics in the specified interval.
@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	clientSetLock sync.Mutex           // clientSetLock protects the clientSet and its config.
+	clusterConfig *rest.Config         // clusterConfig in cluster config used by the clientSet.
+	clientSet     kubernetes.Interface // clientSet shared Kubernetes clientSet.
+)
+
+// GetClusterConfig returns the in cluster config, using the Service Account, Role and RoleBinding of Torch.
+func GetClusterConfig() (*rest.Config, error) {
+	_, err := GetClientSet()
+	return clusterConfig, err
+}
+
+// GetClientSet returns the shared Kubernetes clientSet, it is created the first time and reused afterwards.
+func GetClientSet() (kubernetes.Interface, error) {
+	clientSetLock.Lock()
+	defer clientSetLock.Unlock()
+
+	if clientSet != nil {
+		return clientSet, nil
+	}
+
+	// Authentication in cluster - using Service Account, Role, RoleBinding
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		log.Error("Error getting the in cluster config: ", err)
+		return nil, err
+	}
+
+	// Create the Kubernetes clientSet
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Error("Error creating the Kubernetes clientSet: ", err)
+		return nil, err
+	}
+
+	clusterConfig, clientSet = config, client
+	return clientSet, nil
+}
+
+// SetClientSet replaces the shared Kubernetes clientSet, it is used to run Torch without a cluster,
+// nil restores the in cluster clientSet.
+func SetClientSet(client kubernetes.Interface) {
+	clientSetLock.Lock()
+	defer clientSetLock.Unlock()
+	clusterConfig, clientSet = nil, client
+}
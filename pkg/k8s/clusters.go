@@ -0,0 +1,195 @@
+package k8s
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/jrmanes/torch/config"
+)
+
+// defaultKubeconfigKey key of the kubeconfig in the Secrets of the clusters when secretKey is empty.
+const defaultKubeconfigKey = "kubeconfig"
+
+// ErrUnknownCluster is returned when the cluster is not in the clusters of the config.
+var ErrUnknownCluster = stderrors.New("unknown cluster")
+
+// remoteCluster represents the clients of a remote cluster.
+type remoteCluster struct {
+	client    kubernetes.Interface // client of the cluster.
+	executor  Executor             // executor of the cluster, the shared one is used when it is nil.
+	namespace string               // namespace watched in the cluster.
+}
+
+// clusterContextKey key of the cluster in the contexts.
+type clusterContextKey struct{}
+
+var (
+	clusters     = map[string]*remoteCluster{} // clusters remote clusters by name.
+	clustersLock sync.RWMutex                  // clustersLock protects the clusters.
+)
+
+// WithCluster returns a copy of the context with the cluster, the calls to the Kubernetes API and the remote commands
+// that receive it are sent to the cluster. The empty name is the cluster where Torch runs.
+func WithCluster(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, clusterContextKey{}, name)
+}
+
+// ClusterFromContext returns the cluster of the context, empty for the cluster where Torch runs.
+func ClusterFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(clusterContextKey{}).(string)
+	return name
+}
+
+// RegisterClusters creates the clients of the remote clusters, the credentials are read from their kubeconfig
+// context, or from the Secret in the namespace of Torch.
+func RegisterClusters(ctx context.Context, clusterConfigs []config.Cluster) error {
+	for _, c := range clusterConfigs {
+		if c.Name == "" {
+			return fmt.Errorf("%w: the name of the cluster is empty", ErrUnknownCluster)
+		}
+
+		restConfig, err := clusterRESTConfig(ctx, c)
+		if err != nil {
+			log.Error("Error getting the config of the cluster: [", c.Name, "]: ", err)
+			return err
+		}
+
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			log.Error("Error creating the clientSet of the cluster: [", c.Name, "]: ", err)
+			return err
+		}
+
+		log.Info("Cluster registered: [", c.Name, "], namespace: [", c.Namespace, "]")
+		setCluster(c.Name, &remoteCluster{
+			client:    client,
+			executor:  NewSPDYExecutor(restConfig, client),
+			namespace: c.Namespace,
+		})
+	}
+	return nil
+}
+
+// clusterRESTConfig returns the config of the cluster, using the Secret when it is set, otherwise the kubeconfig.
+func clusterRESTConfig(ctx context.Context, c config.Cluster) (*rest.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: c.Context}
+	if c.Secret == "" {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		if c.Kubeconfig != "" {
+			rules.ExplicitPath = c.Kubeconfig
+		}
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	}
+
+	key := c.SecretKey
+	if key == "" {
+		key = defaultKubeconfigKey
+	}
+	kubeconfig, err := GetSecretKey(ctx, GetCurrentNamespace(), c.Secret, key)
+	if err != nil {
+		return nil, err
+	}
+
+	apiConfig, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return nil, err
+	}
+	return clientcmd.NewNonInteractiveClientConfig(*apiConfig, c.Context, overrides, nil).ClientConfig()
+}
+
+// SetClusterClientSet replaces the clientSet of the remote cluster, it is used to run Torch without the clusters,
+// the remote commands use the shared executor. nil removes the cluster.
+func SetClusterClientSet(name string, client kubernetes.Interface, namespace string) {
+	if client == nil {
+		clustersLock.Lock()
+		defer clustersLock.Unlock()
+		delete(clusters, name)
+		return
+	}
+	setCluster(name, &remoteCluster{client: client, namespace: namespace})
+}
+
+// setCluster stores the clients of the remote cluster.
+func setCluster(name string, cluster *remoteCluster) {
+	clustersLock.Lock()
+	defer clustersLock.Unlock()
+	clusters[name] = cluster
+}
+
+// getCluster returns the clients of the remote cluster.
+func getCluster(name string) (*remoteCluster, error) {
+	clustersLock.RLock()
+	defer clustersLock.RUnlock()
+
+	cluster, ok := clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: [%s]", ErrUnknownCluster, name)
+	}
+	return cluster, nil
+}
+
+// ClusterNames returns the names of the remote clusters sorted.
+func ClusterNames() []string {
+	clustersLock.RLock()
+	defer clustersLock.RUnlock()
+
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetClusterClientSet returns the clientSet of the cluster, the shared one for the cluster where Torch runs.
+func GetClusterClientSet(name string) (kubernetes.Interface, error) {
+	if name == "" {
+		return GetClientSet()
+	}
+
+	cluster, err := getCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.client, nil
+}
+
+// GetClusterExecutor returns the executor of the cluster, the shared one for the cluster where Torch runs and for the
+// clusters without their own executor.
+func GetClusterExecutor(name string) (Executor, error) {
+	if name == "" {
+		return GetExecutor()
+	}
+
+	cluster, err := getCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.executor == nil {
+		return GetExecutor()
+	}
+	return cluster.executor, nil
+}
+
+// ClusterNamespace returns the namespace watched in the cluster, the namespace of Torch by default.
+func ClusterNamespace(name string) string {
+	if name != "" {
+		if cluster, err := getCluster(name); err == nil && cluster.namespace != "" {
+			return cluster.namespace
+		}
+	}
+	return GetCurrentNamespace()
+}
+
+// clientSetFor returns the clientSet of the cluster of the context.
+func clientSetFor(ctx context.Context) (kubernetes.Interface, error) {
+	return GetClusterClientSet(ClusterFromContext(ctx))
+}
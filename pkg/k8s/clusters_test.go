@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jrmanes/torch/config"
+)
+
+// testKubeconfig kubeconfig with two contexts.
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+  - name: east
+    cluster:
+      server: https://east.example.com
+  - name: west
+    cluster:
+      server: https://west.example.com
+users:
+  - name: torch
+    user:
+      token: secret
+contexts:
+  - name: east
+    context: {cluster: east, user: torch}
+  - name: west
+    context: {cluster: west, user: torch}
+current-context: east
+`
+
+func TestClusterRESTConfig(t *testing.T) {
+	SetClientSet(fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "clusters", Namespace: GetCurrentNamespace()},
+		Data:       map[string][]byte{defaultKubeconfigKey: []byte(testKubeconfig), "other": []byte(testKubeconfig)},
+	}))
+	defer SetClientSet(nil)
+
+	tests := []struct {
+		name    string
+		cluster config.Cluster
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Case 1: current context of the kubeconfig of the Secret",
+			cluster: config.Cluster{Name: "east", Secret: "clusters"},
+			want:    "https://east.example.com",
+		},
+		{
+			name:    "Case 2: context and key of the Secret",
+			cluster: config.Cluster{Name: "west", Secret: "clusters", SecretKey: "other", Context: "west"},
+			want:    "https://west.example.com",
+		},
+		{
+			name:    "Case 3: missing key",
+			cluster: config.Cluster{Name: "west", Secret: "clusters", SecretKey: "missing"},
+			wantErr: true,
+		},
+		{
+			name:    "Case 4: missing context",
+			cluster: config.Cluster{Name: "north", Secret: "clusters", Context: "north"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clusterRESTConfig(context.Background(), tt.cluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("clusterRESTConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Host != tt.want {
+				t.Errorf("clusterRESTConfig() host = %v, want %v", got.Host, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterRouting(t *testing.T) {
+	local := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-1-0", Namespace: "default"}})
+	remote := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-2-0", Namespace: "celestia"}})
+	SetClientSet(local)
+	SetClusterClientSet("remote", remote, "celestia")
+	defer func() {
+		SetClientSet(nil)
+		SetClusterClientSet("remote", nil, "")
+	}()
+
+	// Case 1: the calls without cluster are sent to the local cluster.
+	if err := AnnotatePod(context.Background(), "default", "da-bridge-1-0", map[string]string{PeerIDAnnotation: "id-1"}); err != nil {
+		t.Errorf("AnnotatePod() error = %v", err)
+	}
+
+	// Case 2: the calls with the cluster in the context are sent to it.
+	ctx := WithCluster(context.Background(), "remote")
+	if err := AnnotatePod(ctx, "celestia", "da-bridge-2-0", map[string]string{PeerIDAnnotation: "id-2"}); err != nil {
+		t.Errorf("AnnotatePod() error = %v", err)
+	}
+	if id, err := GetPodAnnotation(ctx, "celestia", "da-bridge-2-0", PeerIDAnnotation); err != nil || id != "id-2" {
+		t.Errorf("GetPodAnnotation() = %v, %v, want id-2", id, err)
+	}
+	if got := ClusterNamespace("remote"); got != "celestia" {
+		t.Errorf("ClusterNamespace() = %v, want celestia", got)
+	}
+
+	// Case 3: the unknown clusters return an error.
+	ctx = WithCluster(context.Background(), "unknown")
+	if _, err := GetPodAnnotation(ctx, "celestia", "da-bridge-2-0", PeerIDAnnotation); !errors.Is(err, ErrUnknownCluster) {
+		t.Errorf("GetPodAnnotation() error = %v, want %v", err, ErrUnknownCluster)
+	}
+	if _, err := Exec(ctx, ExecRequest{PodName: "da-bridge-2-0", Command: []string{"true"}}); !errors.Is(err, ErrUnknownCluster) {
+		t.Errorf("Exec() error = %v, want %v", err, ErrUnknownCluster)
+	}
+}
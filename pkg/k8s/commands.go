@@ -1,71 +1,153 @@
 package k8s
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
-	v1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/remotecommand"
+	corev1 "k8s.io/api/core/v1"
 )
 
-// RunRemoteCommand executes a remote command on the specified node.
-func RunRemoteCommand(nodeName, container, namespace string, command []string) (string, error) {
-	clusterConfig, err := rest.InClusterConfig()
-	if err != nil {
-		log.Error("Error: ", err.Error())
+// defaultExecTimeout max time to run a remote command when EXEC_TIMEOUT is empty.
+const defaultExecTimeout = 30 * time.Second
+
+// noSuchFileMessage error printed by cat when the file doesn't exist.
+const noSuchFileMessage = "No such file or directory"
+
+var (
+	// ErrCommandFailed is returned when the remote command finishes with an exit code different to 0.
+	ErrCommandFailed = errors.New("remote command failed")
+	// ErrFileNotFound is returned when the file read from the pod doesn't exist.
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// inflightCommands tracks the remote commands being executed, so Torch waits for them before stopping.
+var inflightCommands sync.WaitGroup
+
+// GetExecTimeout returns the max time to run a remote command, it can be changed with EXEC_TIMEOUT.
+func GetExecTimeout() time.Duration {
+	timeout := os.Getenv("EXEC_TIMEOUT")
+	if timeout == "" {
+		return defaultExecTimeout
+	}
+
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d <= 0 {
+		log.Error("Invalid EXEC_TIMEOUT [", timeout, "], using the default value: ", defaultExecTimeout)
+		return defaultExecTimeout
 	}
-	// creates the client
-	client, err := kubernetes.NewForConfig(clusterConfig)
+	return d
+}
+
+// RunRemoteCommand executes a remote command on the specified node and returns its output.
+func RunRemoteCommand(ctx context.Context, nodeName, container, namespace string, command []string) (string, error) {
+	result, err := Exec(ctx, ExecRequest{
+		Namespace: namespace,
+		PodName:   nodeName,
+		Container: container,
+		Command:   command,
+	})
+	return result.Stdout, err
+}
+
+// ReadRemoteFile returns the content of the file in the container of the pod, ErrFileNotFound if it doesn't exist.
+// The missing file is the expected case of the files not written yet, so it is not reported as a failed command.
+func ReadRemoteFile(ctx context.Context, nodeName, container, namespace, file string) (string, error) {
+	result, err := Exec(ctx, ExecRequest{
+		Namespace: namespace,
+		PodName:   nodeName,
+		Container: container,
+		Command:   ReadFile(file),
+		expected: func(result ExecResult) bool {
+			return strings.Contains(result.Stderr, noSuchFileMessage)
+		},
+	})
+	if errors.Is(err, ErrCommandFailed) && strings.Contains(result.Stderr, noSuchFileMessage) {
+		return "", fmt.Errorf("%w: [%s] in the pod [%s]", ErrFileNotFound, file, nodeName)
+	}
+	return result.Stdout, err
+}
+
+// Exec executes the request with the executor of its cluster once its container is running and there is a free slot
+// in the exec limiter, the cluster of the context is used when the request doesn't have one. It returns
+// ErrContainerNotReady when the container is not running, and ErrCommandFailed when the command finishes with an exit
+// code different to 0.
+func Exec(ctx context.Context, req ExecRequest) (ExecResult, error) {
+	inflightCommands.Add(1)
+	defer inflightCommands.Done()
+
+	if req.Cluster == "" {
+		req.Cluster = ClusterFromContext(ctx)
+	}
+
+	// the exec fails without telling why when the container is not running, e.g. the init container already finished.
+	if err := WaitForContainer(WithCluster(ctx, req.Cluster), req.Namespace, req.PodName, req.Container); err != nil {
+		return ExecResult{}, err
+	}
+
+	// wait until there is a free slot, so we don't run too many commands at the same time.
+	release, err := GetExecLimiter().Acquire(ctx, req.Namespace+"/"+req.PodName)
 	if err != nil {
-		log.Fatalf("Error: %v", err.Error())
+		log.Error("Error waiting to execute the remote command: ", err)
+		return ExecResult{}, err
 	}
+	defer release()
 
-	// Create a request to execute the command on the specified node.
-	req := client.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(nodeName).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&v1.PodExecOptions{
-			Command:   command,
-			Container: container,
-			Stdin:     false,
-			Stdout:    true,
-			Stderr:    true,
-			TTY:       false,
-		}, scheme.ParameterCodec)
-
-	// Execute the remote command.
-	output, err := executeCommand(clusterConfig, req)
+	executor, err := GetClusterExecutor(req.Cluster)
 	if err != nil {
-		log.Error("failed to execute remote command: ", err)
+		log.Error("Error getting the executor: ", err)
+		return ExecResult{}, err
 	}
 
-	return output, nil
-}
+	ctx, cancel := context.WithTimeout(ctx, GetExecTimeout())
+	defer cancel()
 
-// executeCommand executes the remote command using the provided configuration, request, and output writer.
-func executeCommand(config *rest.Config, req *rest.Request) (string, error) {
-	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	result, err := executor.Exec(ctx, req)
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("%w in the pod: [%s], exit code: [%d]: %s",
+			ErrCommandFailed, req.PodName, result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	if err != nil && req.expected != nil && req.expected(result) {
+		return result, err
+	}
 	if err != nil {
-		log.Error("failed to create SPDY executor: ", err)
+		log.Error("failed to execute remote command in the pod: [", req.PodName, "]: ", err)
+		// the events are only recorded in the cluster where Torch runs.
+		if req.Cluster == "" {
+			RecordPodEvent(req.Namespace, req.PodName, corev1.EventTypeWarning, EventExecFailed,
+				"Remote command [%s] failed in the container [%s]: %v", commandName(req.Command), req.Container, err)
+		}
+		return result, err
 	}
 
-	// Prepare the standard I/O streams.
-	var stdout, stderr bytes.Buffer
+	return result, nil
+}
 
-	// Execute the remote command and capture the output.
-	err = executor.Stream(remotecommand.StreamOptions{
-		Stdout: &stdout,
-		Stderr: &stderr,
-		Tty:    false,
-	})
-	if err != nil {
-		log.Error("failed to execute command stream: ", err)
+// commandName returns the name of the command, without its arguments.
+func commandName(command []string) string {
+	if len(command) == 0 {
+		return ""
 	}
+	return command[0]
+}
+
+// WaitForRemoteCommands waits until the remote commands being executed finish or the context is done.
+func WaitForRemoteCommands(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		inflightCommands.Wait()
+		close(done)
+	}()
 
-	return stdout.String(), nil
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
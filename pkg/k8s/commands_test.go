@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// stubExecutor returns the same result for all the commands.
+type stubExecutor struct {
+	result ExecResult
+	err    error
+}
+
+func (s stubExecutor) Exec(context.Context, ExecRequest) (ExecResult, error) {
+	return s.result, s.err
+}
+
+// TestExec validates the errors returned for the commands that fail.
+func TestExec(t *testing.T) {
+	errStream := errors.New("stream closed")
+	tests := []struct {
+		name      string
+		executor  stubExecutor
+		container string
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "Case 1: Command executed",
+			executor:  stubExecutor{result: ExecResult{Stdout: "output"}},
+			container: "da",
+			want:      "output",
+		},
+		{
+			name:      "Case 2: Command finished with an exit code different to 0",
+			executor:  stubExecutor{result: ExecResult{Stderr: "not found", ExitCode: 1}},
+			container: "da",
+			wantErr:   ErrCommandFailed,
+		},
+		{
+			name:      "Case 3: Command couldn't be executed",
+			executor:  stubExecutor{err: errStream},
+			container: "da",
+			wantErr:   errStream,
+		},
+		{
+			name:      "Case 4: Container that already finished",
+			executor:  stubExecutor{result: ExecResult{Stdout: "output"}},
+			container: "da-setup",
+			wantErr:   ErrContainerNotReady,
+		},
+	}
+
+	SetClientSet(fake.NewSimpleClientset(execPod("da-bridge-1-0", "da", "da-setup")))
+	defer SetClientSet(nil)
+	defer SetExecutor(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetExecutor(tt.executor)
+			got, err := RunRemoteCommand(context.Background(), "da-bridge-1-0", tt.container, "default", []string{"true"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RunRemoteCommand() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RunRemoteCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadRemoteFile validates that the missing files are reported as ErrFileNotFound.
+func TestReadRemoteFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor stubExecutor
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "Case 1: File read",
+			executor: stubExecutor{result: ExecResult{Stdout: "content"}},
+			want:     "content",
+		},
+		{
+			name:     "Case 2: File that doesn't exist",
+			executor: stubExecutor{result: ExecResult{Stderr: "cat: /tmp/TP-ADDR: No such file or directory", ExitCode: 1}},
+			wantErr:  ErrFileNotFound,
+		},
+		{
+			name:     "Case 3: File that can't be read",
+			executor: stubExecutor{result: ExecResult{Stderr: "cat: /tmp/TP-ADDR: Permission denied", ExitCode: 1}},
+			wantErr:  ErrCommandFailed,
+		},
+	}
+
+	SetClientSet(fake.NewSimpleClientset(execPod("da-bridge-1-0", "da", "da-setup")))
+	defer SetClientSet(nil)
+	defer SetExecutor(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetExecutor(tt.executor)
+			got, err := ReadRemoteFile(context.Background(), "da-bridge-1-0", "da", "default", "/tmp/TP-ADDR")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadRemoteFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadRemoteFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package k8s
+
+import (
+	"context"
+	stderrors "errors"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	DeliveryExec      = "exec"                         // DeliveryExec the files are written in the containers of the node.
+	DeliveryConfigMap = "configMap"                    // DeliveryConfigMap the files are stored in a ConfigMap mounted by the node.
+	DeliverySecret    = "secret"                       // DeliverySecret the files are stored in a Secret mounted by the node.
+	managedByLabel    = "app.kubernetes.io/managed-by" // managedByLabel label added to the resources created by Torch.
+	managedByValue    = "torch"                        // managedByValue value of the label managedByLabel.
+)
+
+// ErrKeyNotFound is returned when the key is not in the ConfigMap or the Secret.
+var ErrKeyNotFound = stderrors.New("key not found")
+
+// SetConfigMapKey stores the value in the key of the ConfigMap, creating it if it doesn't exist, the rest of the
+// keys are kept.
+func SetConfigMapKey(ctx context.Context, namespace, name, key, value string) error {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return err
+	}
+	return setConfigMapKey(ctx, client, namespace, name, key, value)
+}
+
+// GetConfigMapKey returns the value of the key of the ConfigMap.
+func GetConfigMapKey(ctx context.Context, namespace, name, key string) (string, error) {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	return getConfigMapKey(ctx, client, namespace, name, key)
+}
+
+// SetSecretKey stores the value in the key of the Secret, creating it if it doesn't exist, the rest of the keys are
+// kept.
+func SetSecretKey(ctx context.Context, namespace, name, key, value string) error {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return err
+	}
+	return setSecretKey(ctx, client, namespace, name, key, value)
+}
+
+// GetSecretKey returns the value of the key of the Secret.
+func GetSecretKey(ctx context.Context, namespace, name, key string) (string, error) {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	return getSecretKey(ctx, client, namespace, name, key)
+}
+
+// setConfigMapKey stores the value in the key of the ConfigMap, retrying when it was modified at the same time.
+func setConfigMapKey(ctx context.Context, client kubernetes.Interface, namespace, name, key, value string) error {
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: managedObjectMeta(namespace, name),
+				Data:       map[string]string{key: value},
+			}, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// created by someone else in the meantime, retry it as a conflict.
+				return errors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if current, ok := configMap.Data[key]; ok && current == value {
+			return nil
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = value
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		log.Error("Error updating the ConfigMap: [", name, "]: ", err)
+		return err
+	}
+
+	log.Info("ConfigMap [", name, "] updated, key: [", key, "]")
+	return nil
+}
+
+// getConfigMapKey returns the value of the key of the ConfigMap, ErrKeyNotFound if it doesn't exist.
+func getConfigMapKey(ctx context.Context, client kubernetes.Interface, namespace, name, key string) (string, error) {
+	configMap, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := configMap.Data[key]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// setSecretKey stores the value in the key of the Secret, retrying when it was modified at the same time.
+func setSecretKey(ctx context.Context, client kubernetes.Interface, namespace, name, key, value string) error {
+	secrets := client.CoreV1().Secrets(namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = secrets.Create(ctx, &corev1.Secret{
+				ObjectMeta: managedObjectMeta(namespace, name),
+				Type:       corev1.SecretTypeOpaque,
+				Data:       map[string][]byte{key: []byte(value)},
+			}, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// created by someone else in the meantime, retry it as a conflict.
+				return errors.NewConflict(corev1.Resource("secrets"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if current, ok := secret.Data[key]; ok && string(current) == value {
+			return nil
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[key] = []byte(value)
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		log.Error("Error updating the Secret: [", name, "]: ", err)
+		return err
+	}
+
+	log.Info("Secret [", name, "] updated, key: [", key, "]")
+	return nil
+}
+
+// getSecretKey returns the value of the key of the Secret, ErrKeyNotFound if it doesn't exist.
+func getSecretKey(ctx context.Context, client kubernetes.Interface, namespace, name, key string) (string, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return string(value), nil
+}
+
+// managedObjectMeta returns the metadata of the resources created by Torch.
+func managedObjectMeta(namespace, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels:    map[string]string{managedByLabel: managedByValue},
+	}
+}
@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSetConfigMapKey(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	// Case 1: the ConfigMap is created with the key.
+	if err := setConfigMapKey(ctx, client, "default", "torch-peers", "da-full-1-0", "/dns/da-bridge-1"); err != nil {
+		t.Fatalf("setConfigMapKey() error = %v", err)
+	}
+	configMap, err := client.CoreV1().ConfigMaps("default").Get(ctx, "torch-peers", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if configMap.Labels[managedByLabel] != managedByValue {
+		t.Errorf("labels = %v, want %v=%v", configMap.Labels, managedByLabel, managedByValue)
+	}
+
+	// Case 2: the rest of the keys are kept when the ConfigMap is updated.
+	if err := setConfigMapKey(ctx, client, "default", "torch-peers", "da-full-2-0", "/dns/da-bridge-2"); err != nil {
+		t.Fatalf("setConfigMapKey() error = %v", err)
+	}
+	for key, want := range map[string]string{"da-full-1-0": "/dns/da-bridge-1", "da-full-2-0": "/dns/da-bridge-2"} {
+		if got, err := getConfigMapKey(ctx, client, "default", "torch-peers", key); err != nil || got != want {
+			t.Errorf("getConfigMapKey(%v) = %v, %v, want %v", key, got, err, want)
+		}
+	}
+
+	// Case 3: the keys and the ConfigMaps that don't exist are not found.
+	if _, err := getConfigMapKey(ctx, client, "default", "torch-peers", "da-full-3-0"); err != ErrKeyNotFound {
+		t.Errorf("getConfigMapKey() error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if _, err := getConfigMapKey(ctx, client, "default", "other", "da-full-1-0"); err != ErrKeyNotFound {
+		t.Errorf("getConfigMapKey() error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestSetSecretKey(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	// Case 1: the Secret is created with the key.
+	if err := setSecretKey(ctx, client, "default", "torch-da-full-1-0", "TP-ADDR", "/dns/da-bridge-1"); err != nil {
+		t.Fatalf("setSecretKey() error = %v", err)
+	}
+
+	// Case 2: the value of the key is updated.
+	if err := setSecretKey(ctx, client, "default", "torch-da-full-1-0", "TP-ADDR", "/dns/da-bridge-2"); err != nil {
+		t.Fatalf("setSecretKey() error = %v", err)
+	}
+	if got, err := getSecretKey(ctx, client, "default", "torch-da-full-1-0", "TP-ADDR"); err != nil || got != "/dns/da-bridge-2" {
+		t.Errorf("getSecretKey() = %v, %v, want %v", got, err, "/dns/da-bridge-2")
+	}
+}
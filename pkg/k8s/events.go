@@ -0,0 +1,118 @@
+package k8s
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+)
+
+const (
+	EventIdentityGenerated = "IdentityGenerated" // EventIdentityGenerated the id of the node was generated.
+	EventPeersWritten      = "PeersWritten"      // EventPeersWritten the connections were delivered to the node.
+	EventExecFailed        = "ExecFailed"        // EventExecFailed a remote command failed in the node.
+	EventMaxRetriesReached = "MaxRetriesReached" // EventMaxRetriesReached the node was moved to the dead letter set.
+	eventComponent         = "torch"             // eventComponent source of the events.
+	eventTimeout           = 5 * time.Second     // eventTimeout max time to get the pod of the event.
+)
+
+var (
+	eventRecorder    record.EventRecorder    // eventRecorder used to attach the events to the pods.
+	eventBroadcaster record.EventBroadcaster // eventBroadcaster sends the events to the API server.
+	eventLock        sync.Mutex              // eventLock protects the eventRecorder and the eventBroadcaster.
+)
+
+// GetEventRecorder returns the recorder used to attach the events to the pods, it is created the first time unless
+// another one was set.
+func GetEventRecorder() (record.EventRecorder, error) {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+
+	if eventRecorder != nil {
+		return eventRecorder, nil
+	}
+
+	client, err := GetClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	host := os.Getenv("POD_NAME")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	eventBroadcaster = record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent, Host: host})
+	return eventRecorder, nil
+}
+
+// SetEventRecorder replaces the recorder used to attach the events to the pods, nil restores the default one.
+func SetEventRecorder(recorder record.EventRecorder) {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+	eventRecorder = recorder
+}
+
+// ShutdownEvents stops sending the events to the API server.
+func ShutdownEvents() {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+
+	if eventBroadcaster != nil {
+		eventBroadcaster.Shutdown()
+		eventBroadcaster, eventRecorder = nil, nil
+	}
+}
+
+// RecordPodEvent attaches an event to the pod, so `kubectl describe pod` shows what Torch did,
+// the eventType is corev1.EventTypeNormal or corev1.EventTypeWarning.
+func RecordPodEvent(namespace, podName, eventType, reason, messageFmt string, args ...interface{}) {
+	recorder, err := GetEventRecorder()
+	if err != nil {
+		log.Error("Error getting the event recorder: ", err)
+		return
+	}
+
+	recorder.Eventf(podReference(namespace, podName), eventType, reason, messageFmt, args...)
+}
+
+// podReference returns the reference of the pod, including its uid when the pod exists, the events without the uid
+// of the pod are not shown by `kubectl describe pod`.
+func podReference(namespace, podName string) *corev1.ObjectReference {
+	ref := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Namespace:  namespace,
+		Name:       podName,
+	}
+
+	client, err := GetClientSet()
+	if err != nil {
+		return ref
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		log.Warn("Error getting the pod of the event: [", podName, "]: ", err)
+		return ref
+	}
+
+	podRef, err := reference.GetReference(scheme.Scheme, pod)
+	if err != nil {
+		return ref
+	}
+	return podRef
+}
@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestPodReference validates that the events are attached to the pods by uid.
+func TestPodReference(t *testing.T) {
+	SetClientSet(fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-1-0", Namespace: "default", UID: "uid-1"},
+	}))
+	defer SetClientSet(nil)
+
+	tests := []struct {
+		name    string
+		podName string
+		wantUID string
+	}{
+		{
+			name:    "Case 1: Pod found",
+			podName: "da-bridge-1-0",
+			wantUID: "uid-1",
+		},
+		{
+			name:    "Case 2: Pod not found",
+			podName: "da-bridge-2-0",
+			wantUID: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := podReference("default", tt.podName)
+			if ref.Kind != "Pod" || ref.Name != tt.podName || string(ref.UID) != tt.wantUID {
+				t.Errorf("podReference() = %+v, want pod %v with uid %v", ref, tt.podName, tt.wantUID)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecRequest represents a command to execute in a container of a pod.
+type ExecRequest struct {
+	Cluster   string    // Cluster of the pod, empty for the cluster where Torch runs.
+	Namespace string    // Namespace of the pod.
+	PodName   string    // PodName name of the pod.
+	Container string    // Container where the command is executed.
+	Command   []string  // Command and its arguments, it is not interpreted by a shell.
+	Stdin     io.Reader // Stdin sent to the command, the stdin is not sent when it is nil.
+
+	expected func(ExecResult) bool // expected checks if the command failed in an expected way, it is not reported then.
+}
+
+// ExecResult represents the result of a command executed in a container.
+type ExecResult struct {
+	Stdout   string // Stdout output of the command.
+	Stderr   string // Stderr errors of the command.
+	ExitCode int    // ExitCode exit code of the command.
+}
+
+// Executor executes commands in the containers of the pods. The errors are returned when the command couldn't be
+// executed, the commands that fail are reported with their exit code.
+type Executor interface {
+	Exec(ctx context.Context, req ExecRequest) (ExecResult, error)
+}
+
+var (
+	executor     Executor   // executor used to run the remote commands.
+	executorLock sync.Mutex // executorLock protects the executor.
+)
+
+// GetExecutor returns the executor used to run the remote commands, the SPDY executor is created the first time
+// unless another one was set.
+func GetExecutor() (Executor, error) {
+	executorLock.Lock()
+	defer executorLock.Unlock()
+
+	if executor == nil {
+		config, err := GetClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+		client, err := GetClientSet()
+		if err != nil {
+			return nil, err
+		}
+		executor = NewSPDYExecutor(config, client)
+	}
+	return executor, nil
+}
+
+// SetExecutor replaces the executor used to run the remote commands, nil restores the SPDY executor.
+func SetExecutor(e Executor) {
+	executorLock.Lock()
+	defer executorLock.Unlock()
+	executor = e
+}
+
+// SPDYExecutor executes the commands using the exec subresource of the pods over SPDY, reusing the same client.
+type SPDYExecutor struct {
+	config *rest.Config         // config of the cluster.
+	client kubernetes.Interface // client used to build the requests.
+}
+
+// NewSPDYExecutor returns an executor that uses the config and the client of the cluster.
+func NewSPDYExecutor(config *rest.Config, client kubernetes.Interface) *SPDYExecutor {
+	return &SPDYExecutor{
+		config: config,
+		client: client,
+	}
+}
+
+// Exec executes the command in the container until it finishes or the context is done.
+func (e *SPDYExecutor) Exec(ctx context.Context, req ExecRequest) (ExecResult, error) {
+	// Create a request to execute the command on the specified node.
+	request := e.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(req.PodName).
+		Namespace(req.Namespace).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Command:   req.Command,
+			Container: req.Container,
+			Stdin:     req.Stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+			TTY:       false,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(e.config, "POST", request.URL())
+	if err != nil {
+		log.Error("failed to create SPDY executor: ", err)
+		return ExecResult{}, err
+	}
+
+	// Prepare the standard I/O streams.
+	var stdout, stderr bytes.Buffer
+
+	// Execute the remote command and capture the output.
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  req.Stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Tty:    false,
+	})
+
+	result := ExecResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	// the commands that finish with an exit code different to 0 are not an error of the execution.
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		result.ExitCode = exitErr.ExitStatus()
+		return result, nil
+	}
+
+	return result, err
+}
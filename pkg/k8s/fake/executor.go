@@ -0,0 +1,164 @@
+// Package fake provides a scriptable executor to test the flows that run remote commands without a cluster.
+package fake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+// Handler returns the result of a command, stdin is the content sent to the command.
+type Handler func(req k8s.ExecRequest, stdin string) (k8s.ExecResult, error)
+
+// Call represents a command executed by the executor.
+type Call struct {
+	k8s.ExecRequest
+	Stdin string // Stdin content sent to the command.
+}
+
+// Executor is a scriptable k8s.Executor that simulates the file system of the pods, it implements the commands used
+// to write and read the files (tee, mv, sha256sum and cat), the rest of the commands are handled with On.
+type Executor struct {
+	mu       sync.Mutex
+	handlers map[string]Handler // handlers by the name of the command.
+	files    map[string]string  // files content by namespace/pod:path.
+	calls    []Call             // calls executed in order.
+}
+
+// NewExecutor returns a fake executor without files.
+func NewExecutor() *Executor {
+	e := &Executor{
+		handlers: map[string]Handler{},
+		files:    map[string]string{},
+	}
+	e.handlers["tee"] = e.tee
+	e.handlers["mv"] = e.mv
+	e.handlers["sha256sum"] = e.sha256sum
+	e.handlers["cat"] = e.cat
+	return e
+}
+
+// On replaces the handler of the command, the command is the first element of the request.
+func (e *Executor) On(command string, handler Handler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers[command] = handler
+}
+
+// SetFile sets the content of the file in the pod.
+func (e *Executor) SetFile(namespace, pod, path, content string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.files[fileKey(namespace, pod, path)] = content
+}
+
+// File returns the content of the file in the pod, false if the file doesn't exist.
+func (e *Executor) File(namespace, pod, path string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	content, ok := e.files[fileKey(namespace, pod, path)]
+	return content, ok
+}
+
+// Calls returns the commands executed in order.
+func (e *Executor) Calls() []Call {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Call(nil), e.calls...)
+}
+
+// Exec executes the handler of the command, the commands without handler exit with the code 127.
+func (e *Executor) Exec(ctx context.Context, req k8s.ExecRequest) (k8s.ExecResult, error) {
+	if err := ctx.Err(); err != nil {
+		return k8s.ExecResult{}, err
+	}
+
+	stdin := ""
+	if req.Stdin != nil {
+		data, err := io.ReadAll(req.Stdin)
+		if err != nil {
+			return k8s.ExecResult{}, err
+		}
+		stdin = string(data)
+	}
+
+	e.mu.Lock()
+	e.calls = append(e.calls, Call{ExecRequest: req, Stdin: stdin})
+	var handler Handler
+	if len(req.Command) > 0 {
+		handler = e.handlers[req.Command[0]]
+	}
+	e.mu.Unlock()
+
+	if handler == nil {
+		return exit(127, "command not found"), nil
+	}
+	return handler(req, stdin)
+}
+
+// tee writes the stdin into the file and returns it.
+func (e *Executor) tee(req k8s.ExecRequest, stdin string) (k8s.ExecResult, error) {
+	e.SetFile(req.Namespace, req.PodName, lastArg(req.Command), stdin)
+	return k8s.ExecResult{Stdout: stdin}, nil
+}
+
+// mv renames the file.
+func (e *Executor) mv(req k8s.ExecRequest, _ string) (k8s.ExecResult, error) {
+	if len(req.Command) < 3 {
+		return exit(1, "missing operand"), nil
+	}
+	src := req.Command[len(req.Command)-2]
+	dst := lastArg(req.Command)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	content, ok := e.files[fileKey(req.Namespace, req.PodName, src)]
+	if !ok {
+		return exit(1, "No such file or directory"), nil
+	}
+	delete(e.files, fileKey(req.Namespace, req.PodName, src))
+	e.files[fileKey(req.Namespace, req.PodName, dst)] = content
+	return k8s.ExecResult{}, nil
+}
+
+// sha256sum returns the checksum of the file in the format of sha256sum.
+func (e *Executor) sha256sum(req k8s.ExecRequest, _ string) (k8s.ExecResult, error) {
+	file := lastArg(req.Command)
+	content, ok := e.File(req.Namespace, req.PodName, file)
+	if !ok {
+		return exit(1, "No such file or directory"), nil
+	}
+	sum := sha256.Sum256([]byte(content))
+	return k8s.ExecResult{Stdout: hex.EncodeToString(sum[:]) + "  " + file + "\n"}, nil
+}
+
+// cat returns the content of the file.
+func (e *Executor) cat(req k8s.ExecRequest, _ string) (k8s.ExecResult, error) {
+	content, ok := e.File(req.Namespace, req.PodName, lastArg(req.Command))
+	if !ok {
+		return exit(1, "No such file or directory"), nil
+	}
+	return k8s.ExecResult{Stdout: content}, nil
+}
+
+// exit returns the result of a command that failed.
+func exit(code int, stderr string) k8s.ExecResult {
+	return k8s.ExecResult{Stderr: stderr, ExitCode: code}
+}
+
+// lastArg returns the last argument of the command.
+func lastArg(command []string) string {
+	if len(command) == 0 {
+		return ""
+	}
+	return command[len(command)-1]
+}
+
+// fileKey returns the key of the file in the pod.
+func fileKey(namespace, pod, path string) string {
+	return namespace + "/" + pod + ":" + path
+}
@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tempFileSuffix suffix of the temporary file written before renaming it to the final path.
+const tempFileSuffix = ".torch-tmp"
+
+// ErrChecksumMismatch is returned when the checksum of the file written doesn't match the checksum of the content.
+var ErrChecksumMismatch = stderrors.New("checksum of the file doesn't match the content written")
+
+// WriteFile writes the content into the file of the container. The content is sent through the stdin of the
+// command, so it is never interpreted by a shell, and it is written to a temporary file that is renamed afterwards,
+// so the node never reads a file half written. The file is verified with its checksum once it is renamed.
+func WriteFile(ctx context.Context, nodeName, container, namespace, file, content string) error {
+	tmp := file + tempFileSuffix
+
+	_, err := Exec(ctx, ExecRequest{
+		Namespace: namespace,
+		PodName:   nodeName,
+		Container: container,
+		Command:   TeeFile(tmp),
+		Stdin:     strings.NewReader(content),
+	})
+	if err != nil {
+		log.Error("Error writing the file: [", tmp, "] in the node: [", nodeName, "]: ", err)
+		return err
+	}
+
+	if _, err := RunRemoteCommand(ctx, nodeName, container, namespace, MoveFile(tmp, file)); err != nil {
+		log.Error("Error renaming the file: [", tmp, "] in the node: [", nodeName, "]: ", err)
+		return err
+	}
+
+	output, err := RunRemoteCommand(ctx, nodeName, container, namespace, ChecksumFile(file))
+	if err != nil {
+		log.Error("Error getting the checksum of the file: [", file, "] in the node: [", nodeName, "]: ", err)
+		return err
+	}
+
+	if ParseChecksum(output) != Checksum(content) {
+		log.Error("Error verifying the file: [", file, "] in the node: [", nodeName, "]: ", ErrChecksumMismatch)
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// Checksum returns the sha256 checksum of the content encoded in hex, as sha256sum does.
+func Checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseChecksum returns the checksum of the output of sha256sum, empty if the output is empty.
+func ParseChecksum(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
@@ -0,0 +1,39 @@
+package k8s
+
+import "testing"
+
+// TestParseChecksum validates the checksum of the content against the output of sha256sum.
+func TestParseChecksum(t *testing.T) {
+	content := `/dns/da-bridge-1/tcp/2121/p2p/12D3KooW"$(id)"`
+	sum := Checksum(content)
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "Case 1: Output of sha256sum",
+			output: sum + "  /tmp/TP-ADDR\n",
+			want:   sum,
+		},
+		{
+			name:   "Case 2: Empty output",
+			output: "",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseChecksum(tt.output); got != tt.want {
+				t.Errorf("ParseChecksum() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Case 3: the checksum is the one generated by sha256sum.
+	if got := Checksum("hello"); got != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Checksum() = %v", got)
+	}
+}
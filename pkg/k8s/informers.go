@@ -0,0 +1,172 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+const defaultResyncPeriod = 10 * time.Minute // defaultResyncPeriod how often the informers replay all the resources when INFORMER_RESYNC_PERIOD is empty.
+
+// ErrCacheNotSynced is returned when the informers couldn't list the resources before stopping.
+var ErrCacheNotSynced = errors.New("the informers cache couldn't be synced")
+
+// produceTask publishes the tasks of the nodes, it is replaced in the tests.
+var produceTask = redis.Producer
+
+// GetResyncPeriod returns how often the informers replay all the resources, so Torch checks again the nodes that
+// were not processed, it can be changed with INFORMER_RESYNC_PERIOD.
+func GetResyncPeriod() time.Duration {
+	resync := os.Getenv("INFORMER_RESYNC_PERIOD")
+	if resync == "" {
+		return defaultResyncPeriod
+	}
+
+	d, err := time.ParseDuration(resync)
+	if err != nil || d <= 0 {
+		log.Error("Invalid INFORMER_RESYNC_PERIOD [", resync, "], using the default value: ", defaultResyncPeriod)
+		return defaultResyncPeriod
+	}
+	return d
+}
+
+// RunInformers watches the Pods, the StatefulSets and the Services in the current namespace using shared informers, it blocks
+// until the context is done. The informers re-establish the watches when the API server closes them.
+// Only the pods matching the label selector of the discovery config are discovered.
+func RunInformers(ctx context.Context, discovery config.Discovery) error {
+	client, err := GetClientSet()
+	if err != nil {
+		return err
+	}
+	return runInformers(ctx, client, "", GetCurrentNamespace(), discovery)
+}
+
+// RunClusterInformers watches the Pods, the StatefulSets and the Services in the namespace of the remote cluster, the
+// tasks of the nodes discovered include the cluster, so their commands are sent to it.
+func RunClusterInformers(ctx context.Context, cluster string, discovery config.Discovery) error {
+	client, err := GetClusterClientSet(cluster)
+	if err != nil {
+		return err
+	}
+	return runInformers(ctx, client, cluster, ClusterNamespace(cluster), discovery)
+}
+
+// runInformers registers the handlers of the resources in new informer factories and starts them.
+func runInformers(
+	ctx context.Context,
+	client kubernetes.Interface,
+	cluster, namespace string,
+	discovery config.Discovery,
+) error {
+	selector, err := labels.Parse(discovery.LabelSelector)
+	if err != nil {
+		log.Error("Invalid label selector: [", discovery.LabelSelector, "]: ", err)
+		return err
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		GetResyncPeriod(),
+		informers.WithNamespace(namespace),
+	)
+	// the pods have their own factory, so the label selector doesn't filter the rest of the resources.
+	podFactory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		GetResyncPeriod(),
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selector.String()
+		}),
+	)
+
+	stsLister := factory.Apps().V1().StatefulSets().Lister()
+	stsInformer := factory.Apps().V1().StatefulSets().Informer()
+	if err := addEventHandler(stsInformer, cluster, "statefulsets", statefulSetHandler()); err != nil {
+		return err
+	}
+
+	podInformer := podFactory.Core().V1().Pods().Informer()
+	if err := addEventHandler(podInformer, cluster, "pods", podHandler(stsLister, cluster)); err != nil {
+		return err
+	}
+
+	svcInformer := factory.Core().V1().Services().Informer()
+	if err := addEventHandler(svcInformer, cluster, "services", serviceHandler(cluster)); err != nil {
+		return err
+	}
+
+	log.Info("Starting the informers in the namespace: [", namespace, "], cluster: [", cluster, "], pods selector: [", selector.String(), "]")
+	for _, f := range []informers.SharedInformerFactory{factory, podFactory} {
+		f.Start(ctx.Done())
+		defer f.Shutdown()
+
+		for informerType, synced := range f.WaitForCacheSync(ctx.Done()) {
+			if !synced && ctx.Err() == nil {
+				log.Error("Error syncing the informer: [", informerType, "]")
+				return ErrCacheNotSynced
+			}
+		}
+	}
+
+	<-ctx.Done()
+	log.Info("Stopping the informers")
+	return nil
+}
+
+// addEventHandler adds the handler to the informer, recording the time of the events received in the cluster and
+// logging the errors of the watch, the informer retries them automatically.
+func addEventHandler(
+	informer cache.SharedIndexInformer,
+	cluster, resource string,
+	handler cache.ResourceEventHandlerFuncs,
+) error {
+	err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		log.Warn("The watch of the ", resource, " was closed, re-establishing it: ", err)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			metrics.RecordWatcherEvent(cluster, resource)
+			if handler.AddFunc != nil {
+				handler.AddFunc(obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			metrics.RecordWatcherEvent(cluster, resource)
+			if handler.UpdateFunc != nil {
+				handler.UpdateFunc(oldObj, newObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			metrics.RecordWatcherEvent(cluster, resource)
+			if handler.DeleteFunc != nil {
+				handler.DeleteFunc(unwrapTombstone(obj))
+			}
+		},
+	})
+	return err
+}
+
+// unwrapTombstone returns the object deleted, when the informer missed the delete event, it receives a tombstone
+// with the last state known of the object.
+func unwrapTombstone(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
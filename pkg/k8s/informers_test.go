@@ -0,0 +1,226 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+// newStatefulSet returns a StatefulSet with the number of replicas.
+func newStatefulSet(name string, replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ResourceVersion: "1"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+}
+
+// newPod returns a pod owned by the controller, ready if specified.
+func newPod(name, ownerKind, ownerName string, ready bool) *corev1.Pod {
+	controller := true
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			ResourceVersion: "1",
+			OwnerReferences: []metav1.OwnerReference{{Kind: ownerKind, Name: ownerName, Controller: &controller}},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+// waitFor waits until the channel receives a value and returns it.
+func waitFor(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the event")
+	}
+	return ""
+}
+
+func TestRunInformers(t *testing.T) {
+	produced := make(chan string, 10)
+	produceTask = func(task redis.NodeTask, queueName string) error {
+		produced <- task.NodeName
+		// a failure publishing a task must not stop the informers.
+		return errors.New("redis is down")
+	}
+	removed := make(chan string, 10)
+	defaultRemoveNode := removeNode
+	removeNode = func(nodeName string) error {
+		removed <- nodeName
+		return nil
+	}
+	defer func() {
+		produceTask = redis.Producer
+		removeNode = defaultRemoveNode
+	}()
+
+	client := fake.NewSimpleClientset(
+		newStatefulSet("da-bridge-1", 2),
+		newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true),
+		newPod("redis-0", kindStatefulSet, "redis", true),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runInformers(ctx, client, "", "default", config.Discovery{})
+	}()
+
+	// Case 1: the ready pods of the nodes that already exist are published, the rest of the pods are skipped.
+	if got := waitFor(t, produced); got != "da-bridge-1-0" {
+		t.Errorf("task published = %v, want da-bridge-1-0", got)
+	}
+
+	// Case 2: the pods are published when they become ready, even if the previous task failed.
+	pods := client.CoreV1().Pods("default")
+	pod := newPod("da-bridge-1-1", kindStatefulSet, "da-bridge-1", false)
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	pod = newPod("da-bridge-1-1", kindStatefulSet, "da-bridge-1", true)
+	pod.ResourceVersion = "2"
+	if _, err := pods.Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := waitFor(t, produced); got != "da-bridge-1-1" {
+		t.Errorf("task published = %v, want da-bridge-1-1", got)
+	}
+
+	// Case 3: the pods of the Deployments are published with the name of the pod.
+	pod = newPod("da-light-7d9f-abcde", kindReplicaSet, "da-light-7d9f", true)
+	pod.Labels = map[string]string{podTemplateHashLabel: "7d9f"}
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := waitFor(t, produced); got != "da-light-7d9f-abcde" {
+		t.Errorf("task published = %v, want da-light-7d9f-abcde", got)
+	}
+
+	// Case 4: the pods removed by a scale down are deleted, the rest of them are kept as they are recreated.
+	sts := newStatefulSet("da-bridge-1", 1)
+	sts.ResourceVersion = "2"
+	if _, err := client.AppsV1().StatefulSets("default").Update(ctx, sts, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// wait until the informer receives the new number of replicas.
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range []string{"da-bridge-1-0", "da-bridge-1-1", "da-light-7d9f-abcde"} {
+		if err := pods.Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	if got := waitFor(t, removed); got != "da-bridge-1-1" {
+		t.Errorf("node removed = %v, want da-bridge-1-1", got)
+	}
+	if got := waitFor(t, removed); got != "da-light-7d9f-abcde" {
+		t.Errorf("node removed = %v, want da-light-7d9f-abcde", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("runInformers() error = %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("node removed = %v, want none", <-removed)
+	}
+}
+
+func TestRunInformersLabelSelector(t *testing.T) {
+	produced := make(chan string, 10)
+	produceTask = func(task redis.NodeTask, queueName string) error {
+		produced <- task.NodeName
+		return nil
+	}
+	defer func() {
+		produceTask = redis.Producer
+	}()
+
+	selected := newPod("celestia-light-0", kindStatefulSet, "celestia-light", true)
+	selected.Labels = map[string]string{"app": "celestia"}
+	selected.Annotations = map[string]string{NodeTypeAnnotation: "da"}
+	client := fake.NewSimpleClientset(
+		newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true),
+		selected,
+	)
+
+	// Case 1: an invalid selector is rejected.
+	if err := runInformers(context.Background(), client, "", "default", config.Discovery{LabelSelector: "app in"}); err == nil {
+		t.Error("runInformers() error = nil, want an error")
+	}
+
+	// Case 2: only the pods matching the selector are discovered.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runInformers(ctx, client, "", "default", config.Discovery{LabelSelector: "app=celestia"})
+	}()
+
+	if got := waitFor(t, produced); got != "celestia-light-0" {
+		t.Errorf("task published = %v, want celestia-light-0", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("runInformers() error = %v", err)
+	}
+	if len(produced) != 0 {
+		t.Errorf("task published = %v, want none", <-produced)
+	}
+}
+
+func TestPodHandlerResync(t *testing.T) {
+	var failure error
+	produced := make(chan string, 10)
+	produceTask = func(task redis.NodeTask, queueName string) error {
+		produced <- task.NodeName
+		return failure
+	}
+	defer func() { produceTask = redis.Producer }()
+
+	handler := podHandler(nil, "")
+	notReady := newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", false)
+	ready := newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true)
+	ready.ResourceVersion = "2"
+
+	// Case 1: the pod is published when it becomes ready.
+	handler.UpdateFunc(notReady, ready)
+	if len(produced) != 1 || <-produced != "da-bridge-1-0" {
+		t.Fatalf("the pod that became ready was not published")
+	}
+
+	// Case 2: the resyncs don't publish the pods already published.
+	handler.UpdateFunc(ready, ready)
+	if len(produced) != 0 {
+		t.Errorf("task published on the resync = %v, want none", <-produced)
+	}
+
+	// Case 3: the resyncs publish the pods whose task couldn't be published until it succeeds.
+	failure = errors.New("redis is down")
+	handler.UpdateFunc(notReady, ready)
+	failure = nil
+	handler.UpdateFunc(ready, ready)
+	handler.UpdateFunc(ready, ready)
+	if len(produced) != 2 {
+		t.Errorf("tasks published = %d, want 2", len(produced))
+	}
+}
@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseName   = "torch-leader"   // defaultLeaseName name of the Lease used when LEADER_ELECTION_LEASE_NAME is empty.
+	leaseDuration      = 15 * time.Second // leaseDuration time that the followers wait before trying to acquire the Lease.
+	renewDeadline      = 10 * time.Second // renewDeadline time that the leader retries to renew the Lease before giving up.
+	retryPeriod        = 2 * time.Second  // retryPeriod time between the attempts to acquire or renew the Lease.
+	identityAddrSuffix = "@"              // identityAddrSuffix separator between the pod name and its address in the identity.
+)
+
+// ErrLeadershipLost is returned when this replica loses the Lease while it is still running.
+var ErrLeadershipLost = errors.New("leadership lost")
+
+var (
+	isLeader      atomic.Bool // isLeader true when this replica holds the Lease.
+	currentLeader string      // currentLeader identity of the replica holding the Lease.
+	leaderMutex   sync.RWMutex
+)
+
+// LeaderElectionEnabled checks if Torch has to run the leader election, it is enabled with LEADER_ELECTION=true.
+func LeaderElectionEnabled() bool {
+	return os.Getenv("LEADER_ELECTION") == "true"
+}
+
+// GetLeaseName returns the name of the Lease used for the leader election.
+func GetLeaseName() string {
+	leaseName := os.Getenv("LEADER_ELECTION_LEASE_NAME")
+	if leaseName == "" {
+		return defaultLeaseName
+	}
+	return leaseName
+}
+
+// GetIdentity returns the identity of this replica, it includes the pod IP when POD_IP is defined,
+// so the followers know where to forward the requests to the leader.
+func GetIdentity() string {
+	name := os.Getenv("POD_NAME")
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+
+	if ip := os.Getenv("POD_IP"); ip != "" {
+		return name + identityAddrSuffix + ip
+	}
+	return name
+}
+
+// IsLeader returns true if this replica is the leader, when the leader election is disabled, it is always the leader.
+func IsLeader() bool {
+	return !LeaderElectionEnabled() || isLeader.Load()
+}
+
+// GetLeader returns the identity of the current leader.
+func GetLeader() string {
+	leaderMutex.RLock()
+	defer leaderMutex.RUnlock()
+	return currentLeader
+}
+
+// GetLeaderAddress returns the address of the current leader, empty if it is unknown.
+func GetLeaderAddress() string {
+	_, addr, found := strings.Cut(GetLeader(), identityAddrSuffix)
+	if !found {
+		return ""
+	}
+	return addr
+}
+
+// RunLeaderElection runs the leader election using a coordination.k8s.io Lease in the current namespace.
+// run is called when this replica becomes the leader, its context is canceled when the Lease is lost.
+// It blocks until run returns, returning ErrLeadershipLost if the Lease was lost before the context was canceled.
+func RunLeaderElection(ctx context.Context, run func(ctx context.Context)) error {
+	client, err := GetClientSet()
+	if err != nil {
+		return err
+	}
+
+	identity := GetIdentity()
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      GetLeaseName(),
+			Namespace: GetCurrentNamespace(),
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	log.Info("Starting leader election, Lease: [", lock.LeaseMeta.Name, "] identity: [", identity, "]")
+
+	// stopped is closed when run returns, so we don't start it again while the previous one is still stopping.
+	var started atomic.Bool
+	stopped := make(chan struct{})
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Info("Leadership acquired: [", identity, "]")
+				isLeader.Store(true)
+				started.Store(true)
+				defer close(stopped)
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				log.Warn("Leadership lost: [", identity, "]")
+				isLeader.Store(false)
+			},
+			OnNewLeader: func(leader string) {
+				log.Info("New leader elected: [", leader, "]")
+				leaderMutex.Lock()
+				currentLeader = leader
+				leaderMutex.Unlock()
+			},
+		},
+	})
+
+	if started.Load() {
+		<-stopped
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return ErrLeadershipLost
+}
@@ -0,0 +1,125 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+const (
+	defaultExecMaxConcurrency = 10 // defaultExecMaxConcurrency max remote commands running at the same time when EXEC_MAX_CONCURRENCY is empty.
+	defaultExecMaxPerTarget   = 2  // defaultExecMaxPerTarget max remote commands running at the same time in a pod when EXEC_MAX_PER_TARGET is empty.
+)
+
+var (
+	execLimiterOnce sync.Once    // execLimiterOnce makes sure that we only create the limiter once.
+	execLimiter     *ExecLimiter // execLimiter limiter shared by all the remote commands.
+)
+
+// ExecLimiter limits the number of remote commands running at the same time, globally and per target pod,
+// so Torch doesn't overload the API server nor a single node.
+type ExecLimiter struct {
+	global    *semaphore.Weighted
+	size      int64
+	perTarget int64
+	inUse     atomic.Int64
+
+	mu      sync.Mutex
+	targets map[string]*targetSemaphore
+}
+
+// targetSemaphore limits the remote commands of a target, refs is used to remove it when it is not used anymore.
+type targetSemaphore struct {
+	sem  *semaphore.Weighted
+	refs int
+}
+
+// NewExecLimiter returns a limiter that runs up to size commands at the same time and up to perTarget in the same target.
+func NewExecLimiter(size, perTarget int) *ExecLimiter {
+	return &ExecLimiter{
+		global:    semaphore.NewWeighted(int64(size)),
+		size:      int64(size),
+		perTarget: int64(perTarget),
+		targets:   make(map[string]*targetSemaphore),
+	}
+}
+
+// GetExecLimiter returns the limiter shared by the remote commands, configured with EXEC_MAX_CONCURRENCY and
+// EXEC_MAX_PER_TARGET.
+func GetExecLimiter() *ExecLimiter {
+	execLimiterOnce.Do(func() {
+		execLimiter = NewExecLimiter(
+			GetEnvInt("EXEC_MAX_CONCURRENCY", defaultExecMaxConcurrency),
+			GetEnvInt("EXEC_MAX_PER_TARGET", defaultExecMaxPerTarget),
+		)
+		if err := metrics.WithMetricsPool("exec", execLimiter.size, execLimiter.InUse); err != nil {
+			log.Error("Error registering the metrics of the exec limiter: ", err)
+		}
+	})
+	return execLimiter
+}
+
+// Acquire waits until the command can run in the target, the returned function must be called when it finishes.
+func (l *ExecLimiter) Acquire(ctx context.Context, target string) (func(), error) {
+	ts := l.getTarget(target)
+	// we wait for the target first, so the commands waiting for a busy node don't take the global slots.
+	if err := ts.sem.Acquire(ctx, 1); err != nil {
+		l.putTarget(target)
+		return nil, err
+	}
+	if err := l.global.Acquire(ctx, 1); err != nil {
+		ts.sem.Release(1)
+		l.putTarget(target)
+		return nil, err
+	}
+	l.inUse.Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.inUse.Add(-1)
+			l.global.Release(1)
+			ts.sem.Release(1)
+			l.putTarget(target)
+		})
+	}, nil
+}
+
+// InUse returns the number of commands running.
+func (l *ExecLimiter) InUse() int64 {
+	return l.inUse.Load()
+}
+
+// getTarget returns the semaphore of the target, creating it if it doesn't exist.
+func (l *ExecLimiter) getTarget(target string) *targetSemaphore {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ts, ok := l.targets[target]
+	if !ok {
+		ts = &targetSemaphore{sem: semaphore.NewWeighted(l.perTarget)}
+		l.targets[target] = ts
+	}
+	ts.refs++
+	return ts
+}
+
+// putTarget removes the semaphore of the target when nobody is using it.
+func (l *ExecLimiter) putTarget(target string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ts, ok := l.targets[target]
+	if !ok {
+		return
+	}
+	ts.refs--
+	if ts.refs <= 0 {
+		delete(l.targets, target)
+	}
+}
@@ -0,0 +1,52 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecLimiter(t *testing.T) {
+	limiter := NewExecLimiter(2, 1)
+	ctx := context.Background()
+
+	release, err := limiter.Acquire(ctx, "default/da-bridge-1-0")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	// Case 1: only one command can run in the same target.
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(waitCtx, "default/da-bridge-1-0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Case 2: a command can run in a different target while there are free slots.
+	releaseOther, err := limiter.Acquire(ctx, "default/da-full-1-0")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if got := limiter.InUse(); got != 2 {
+		t.Errorf("InUse() = %v, want 2", got)
+	}
+
+	// Case 3: no more commands can run when all the global slots are in use.
+	waitCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(waitCtx, "default/da-full-2-0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Case 4: the slots are free again once the commands finish.
+	release()
+	release() // releasing twice has no effect.
+	releaseOther()
+	if got := limiter.InUse(); got != 0 {
+		t.Errorf("InUse() = %v, want 0", got)
+	}
+	if len(limiter.targets) != 0 {
+		t.Errorf("targets = %v, want none", len(limiter.targets))
+	}
+}
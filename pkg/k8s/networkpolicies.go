@@ -0,0 +1,410 @@
+package k8s
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+
+	"github.com/jrmanes/torch/config"
+)
+
+const (
+	networkPolicyPrefix = "torch-"                             // networkPolicyPrefix prefix of the NetworkPolicies created for every node.
+	podNameLabel        = "statefulset.kubernetes.io/pod-name" // podNameLabel label with the name of the pods of the StatefulSets.
+	namespaceNameLabel  = "kubernetes.io/metadata.name"        // namespaceNameLabel label with the name of the namespaces.
+	daP2PPort           = 2121                                 // daP2PPort port used by the DA nodes to connect to each other.
+	consensusP2PPort    = 26656                                // consensusP2PPort port used by the consensus nodes to connect to each other.
+	consensusRPCPort    = 26657                                // consensusRPCPort RPC port of the consensus nodes used by the DA nodes.
+	consensusGRPCPort   = 9090                                 // consensusGRPCPort gRPC port of the consensus nodes used by the DA nodes.
+	networkPolicyKind   = "NetworkPolicy"                      // networkPolicyKind kind of the NetworkPolicies.
+	networkPolicyAPI    = networkingv1.GroupName + "/v1"       // networkPolicyAPI apiVersion of the NetworkPolicies.
+	yamlSeparator       = "---\n"                              // yamlSeparator separator of the documents of the manifests.
+	defaultTargetSuffix = "-0"                                 // defaultTargetSuffix pod of the StatefulSets referenced by their service.
+	defaultTorchLabels  = "app=torch"                          // defaultTorchLabels labels of the pods of Torch when NETWORK_POLICIES_TORCH_SELECTOR is empty.
+)
+
+// address matches the connections that are addresses instead of nodes.
+var address = regexp.MustCompile(`^/(ip4|ip6|dns|dns4|dns6)/|[:@]`)
+
+// NetworkPoliciesEnabled checks if Torch has to apply the NetworkPolicies of the nodes when it starts, it is enabled
+// with NETWORK_POLICIES=true.
+func NetworkPoliciesEnabled() bool {
+	return os.Getenv("NETWORK_POLICIES") == "true"
+}
+
+// GetTorchSelector returns the selector of the pods of Torch, they are allowed to reach the RPC port of the consensus
+// nodes to get their ids, it can be changed with NETWORK_POLICIES_TORCH_SELECTOR.
+func GetTorchSelector() *metav1.LabelSelector {
+	selector := os.Getenv("NETWORK_POLICIES_TORCH_SELECTOR")
+	if selector == "" {
+		selector = defaultTorchLabels
+	}
+
+	labelSelector, err := metav1.ParseToLabelSelector(selector)
+	if err != nil {
+		log.Error("Invalid NETWORK_POLICIES_TORCH_SELECTOR [", selector, "], using the default value: ", defaultTorchLabels)
+		labelSelector, _ = metav1.ParseToLabelSelector(defaultTorchLabels)
+	}
+	return labelSelector
+}
+
+// edge represents a connection from a node to another node of the same cluster.
+type edge struct {
+	source     config.Peer // source node that opens the connection.
+	targetPod  string      // targetPod pod that receives the connection.
+	namespace  string      // namespace of the target pod.
+	targetType string      // targetType type of the target node.
+}
+
+// BuildNetworkPolicies returns the NetworkPolicies of the nodes of the cluster, one per node that receives
+// connections, allowing the ingress only from the nodes that connect to it in the config and only in the ports of its
+// type: the P2P port for the DA nodes, the P2P port for the consensus nodes connecting to consensus nodes and the RPC
+// and gRPC ports for the DA nodes connecting to consensus nodes. The pods of Torch can reach the RPC port of the
+// consensus nodes of its cluster. The connections between clusters reach the nodes through their Load Balancers, so
+// the nodes that receive connections from other clusters or have an ExternalService allow the ingress from any
+// source in their P2P port.
+func BuildNetworkPolicies(cfg config.MutualPeersConfig, cluster string) []networkingv1.NetworkPolicy {
+	namespace := configClusterNamespace(cfg, cluster)
+	peers := map[string]config.Peer{}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			peers[peer.NodeName] = peer
+		}
+	}
+
+	// group the edges by the pod that receives the connections.
+	edges := map[string][]edge{}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, source := range mutualPeer.Peers {
+			if source.Cluster != cluster {
+				continue
+			}
+			if source.Namespace == "" {
+				source.Namespace = namespace
+			}
+
+			for _, conn := range source.ConnectsTo {
+				e, ok := newEdge(source, conn, namespace, peers)
+				if !ok {
+					continue
+				}
+				key := e.namespace + "/" + e.targetPod
+				edges[key] = append(edges[key], e)
+			}
+		}
+	}
+
+	external := externalTargets(cfg, cluster, namespace, peers)
+	keys := make([]string, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	policies := make([]networkingv1.NetworkPolicy, 0, len(keys))
+	for _, key := range keys {
+		policy := networkPolicy(edges[key])
+		if external[key] {
+			policy.Spec.Ingress = append(policy.Spec.Ingress, externalIngressRule(edges[key][0].targetType))
+		}
+		if cluster == "" && edges[key][0].targetType == "consensus" {
+			policy.Spec.Ingress = append(policy.Spec.Ingress, torchIngressRule(policy.Namespace))
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// configClusterNamespace returns the namespace watched in the cluster of the config, the namespace of Torch by default.
+func configClusterNamespace(cfg config.MutualPeersConfig, cluster string) string {
+	for _, c := range cfg.Clusters {
+		if c.Name == cluster && c.Namespace != "" {
+			return c.Namespace
+		}
+	}
+	return GetCurrentNamespace()
+}
+
+// newEdge returns the edge of the connection of the source node, false for the connections that are addresses or
+// nodes of other clusters. The nodes that are not in the config run in the namespace watched in the cluster, and the
+// nodes connecting with ENV vars reference the service of the StatefulSet, so the first pod of the StatefulSet
+// receives the connection.
+func newEdge(source config.Peer, conn, namespace string, peers map[string]config.Peer) (edge, bool) {
+	if address.MatchString(conn) {
+		return edge{}, false
+	}
+
+	e := edge{source: source, targetPod: conn, namespace: namespace}
+	if target, ok := peers[conn]; ok {
+		if target.Cluster != source.Cluster {
+			return edge{}, false
+		}
+		if target.Namespace != "" {
+			e.namespace = target.Namespace
+		}
+		e.targetType = target.NodeType
+	} else if source.ConnectsAsEnvVar {
+		e.targetPod = conn + defaultTargetSuffix
+	}
+
+	if e.targetType == "" {
+		e.targetType = NodeTypeFromName(e.targetPod)
+	}
+	return e, true
+}
+
+// externalTargets returns the pods of the nodes of the cluster that are reached from outside of it: the nodes with an
+// ExternalService and the nodes that receive connections from the nodes of other clusters.
+func externalTargets(cfg config.MutualPeersConfig, cluster, namespace string, peers map[string]config.Peer) map[string]bool {
+	targetKey := func(peer config.Peer) string {
+		if peer.Namespace != "" {
+			return peer.Namespace + "/" + peer.NodeName
+		}
+		return namespace + "/" + peer.NodeName
+	}
+
+	targets := map[string]bool{}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, source := range mutualPeer.Peers {
+			if source.Cluster == cluster {
+				if source.ExternalService != "" {
+					targets[targetKey(source)] = true
+				}
+				continue
+			}
+
+			for _, conn := range source.ConnectsTo {
+				if target, ok := peers[conn]; ok && target.Cluster == cluster {
+					targets[targetKey(target)] = true
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// externalIngressRule returns the rule that allows any source to reach the P2P port of the node, used by the
+// connections that come through its Load Balancer.
+func externalIngressRule(targetType string) networkingv1.NetworkPolicyIngressRule {
+	// the sources are not nodes of the cluster, so the ports are the P2P ports of the type of the node.
+	return networkingv1.NetworkPolicyIngressRule{Ports: edgePorts("", targetType)}
+}
+
+// networkPolicy returns the NetworkPolicy of the pod that receives the connections of the edges.
+func networkPolicy(edges []edge) networkingv1.NetworkPolicy {
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].source.NodeName < edges[j].source.NodeName
+	})
+
+	target := edges[0]
+	rules := make([]networkingv1.NetworkPolicyIngressRule, 0, len(edges))
+	for _, e := range edges {
+		peer := networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{podNameLabel: e.source.NodeName}},
+		}
+		if e.source.Namespace != e.namespace {
+			peer.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{namespaceNameLabel: e.source.Namespace},
+			}
+		}
+
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+			From:  []networkingv1.NetworkPolicyPeer{peer},
+			Ports: edgePorts(e.source.NodeType, e.targetType),
+		})
+	}
+
+	return networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{Kind: networkPolicyKind, APIVersion: networkPolicyAPI},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyPrefix + target.targetPod,
+			Namespace: target.namespace,
+			Labels:    map[string]string{managedByLabel: managedByValue},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{podNameLabel: target.targetPod}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     rules,
+		},
+	}
+}
+
+// torchIngressRule returns the rule that allows the pods of Torch to reach the RPC port of the consensus nodes.
+func torchIngressRule(namespace string) networkingv1.NetworkPolicyIngressRule {
+	peer := networkingv1.NetworkPolicyPeer{PodSelector: GetTorchSelector()}
+	if current := GetCurrentNamespace(); current != namespace {
+		peer.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: current}}
+	}
+	return networkingv1.NetworkPolicyIngressRule{
+		From:  []networkingv1.NetworkPolicyPeer{peer},
+		Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, consensusRPCPort)},
+	}
+}
+
+// edgePorts returns the ports used by the source node to connect to the target node depending on their types.
+func edgePorts(sourceType, targetType string) []networkingv1.NetworkPolicyPort {
+	switch {
+	case targetType == "da":
+		// the DA nodes use TCP and QUIC in the P2P port.
+		return []networkingv1.NetworkPolicyPort{
+			policyPort(corev1.ProtocolTCP, daP2PPort),
+			policyPort(corev1.ProtocolUDP, daP2PPort),
+		}
+	case targetType == "consensus" && sourceType == "da":
+		return []networkingv1.NetworkPolicyPort{
+			policyPort(corev1.ProtocolTCP, consensusRPCPort),
+			policyPort(corev1.ProtocolTCP, consensusGRPCPort),
+		}
+	case targetType == "consensus":
+		return []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, consensusP2PPort)}
+	}
+	// the ports of the unknown nodes are not known, all of them are allowed from the source node.
+	return nil
+}
+
+// policyPort returns the port of the NetworkPolicy.
+func policyPort(protocol corev1.Protocol, port int) networkingv1.NetworkPolicyPort {
+	p := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
+
+// NetworkPoliciesYAML returns the NetworkPolicies as YAML documents.
+func NetworkPoliciesYAML(policies []networkingv1.NetworkPolicy) ([]byte, error) {
+	objects := make([]interface{}, 0, len(policies))
+	for _, policy := range policies {
+		objects = append(objects, policy)
+	}
+	return manifestsYAML(objects)
+}
+
+// manifestsYAML returns the objects as YAML documents.
+func manifestsYAML(objects []interface{}) ([]byte, error) {
+	var out []byte
+	for _, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, yamlSeparator...)
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// ApplyNetworkPolicies creates or updates the NetworkPolicies in the cluster of the context, and deletes the
+// NetworkPolicies managed by Torch in the namespaces that are not in the list anymore, e.g. the nodes or the
+// connections removed from the config.
+func ApplyNetworkPolicies(ctx context.Context, namespaces []string, policies []networkingv1.NetworkPolicy) error {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied := map[string]bool{}
+	for _, policy := range policies {
+		if err := applyNetworkPolicy(ctx, client, policy); err != nil {
+			log.Error("Error applying the NetworkPolicy: [", policy.Name, "]: ", err)
+			return err
+		}
+		applied[policy.Namespace+"/"+policy.Name] = true
+		log.Info("NetworkPolicy [", policy.Name, "] applied in the namespace: [", policy.Namespace, "]")
+	}
+
+	for _, namespace := range namespaces {
+		if err := pruneNetworkPolicies(ctx, client, namespace, applied); err != nil {
+			log.Error("Error deleting the NetworkPolicies of the namespace: [", namespace, "]: ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// NetworkPolicyNamespaces returns the namespaces where Torch manages the NetworkPolicies of the cluster: the namespace
+// watched in the cluster and the namespaces of its nodes.
+func NetworkPolicyNamespaces(cfg config.MutualPeersConfig, cluster string) []string {
+	namespaces := map[string]bool{configClusterNamespace(cfg, cluster): true}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if peer.Cluster == cluster && peer.Namespace != "" {
+				namespaces[peer.Namespace] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		keys = append(keys, namespace)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SyncNetworkPolicies applies the NetworkPolicies of the nodes of the config in the cluster where Torch runs and in
+// the remote clusters, deleting the ones that are not generated anymore.
+func SyncNetworkPolicies(ctx context.Context, cfg config.MutualPeersConfig) error {
+	for _, cluster := range append([]string{""}, ClusterNames()...) {
+		policies := BuildNetworkPolicies(cfg, cluster)
+		if err := ApplyNetworkPolicies(WithCluster(ctx, cluster), NetworkPolicyNamespaces(cfg, cluster), policies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneNetworkPolicies deletes the NetworkPolicies managed by Torch in the namespace that were not applied.
+func pruneNetworkPolicies(ctx context.Context, client kubernetes.Interface, namespace string, applied map[string]bool) error {
+	policies := client.NetworkingV1().NetworkPolicies(namespace)
+	list, err := policies.List(ctx, metav1.ListOptions{LabelSelector: managedByLabel + "=" + managedByValue})
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range list.Items {
+		if applied[policy.Namespace+"/"+policy.Name] {
+			continue
+		}
+		if err := policies.Delete(ctx, policy.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("NetworkPolicy [", policy.Name, "] deleted from the namespace: [", namespace, "]")
+	}
+	return nil
+}
+
+// applyNetworkPolicy creates the NetworkPolicy or replaces its spec, retrying when it was modified at the same time.
+func applyNetworkPolicy(ctx context.Context, client kubernetes.Interface, policy networkingv1.NetworkPolicy) error {
+	policies := client.NetworkingV1().NetworkPolicies(policy.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := policies.Get(ctx, policy.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = policies.Create(ctx, &policy, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// created by someone else in the meantime, retry it as a conflict.
+				return errors.NewConflict(networkingv1.Resource("networkpolicies"), policy.Name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.Labels == nil {
+			current.Labels = map[string]string{}
+		}
+		current.Labels[managedByLabel] = managedByValue
+		current.Spec = policy.Spec
+		_, err = policies.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+}
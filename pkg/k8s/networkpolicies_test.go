@@ -0,0 +1,252 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jrmanes/torch/config"
+)
+
+// policySummary is the part of a NetworkPolicy validated in the tests.
+type policySummary struct {
+	name      string
+	namespace string
+	rules     map[string][]string // rules sources of the ingress rules and their ports.
+}
+
+// summarizePolicies returns the summary of the NetworkPolicies.
+func summarizePolicies(policies []networkingv1.NetworkPolicy) []policySummary {
+	summaries := make([]policySummary, 0, len(policies))
+	for _, policy := range policies {
+		s := policySummary{name: policy.Name, namespace: policy.Namespace, rules: map[string][]string{}}
+		for _, rule := range policy.Spec.Ingress {
+			// the rules without sources allow any source.
+			from := "*"
+			if len(rule.From) > 0 {
+				from = metav1.FormatLabelSelector(rule.From[0].PodSelector)
+				if rule.From[0].NamespaceSelector != nil {
+					from = metav1.FormatLabelSelector(rule.From[0].NamespaceSelector) + "/" + from
+				}
+			}
+
+			ports := []string{}
+			for _, port := range rule.Ports {
+				ports = append(ports, string(*port.Protocol)+"/"+port.Port.String())
+			}
+			s.rules[from] = ports
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+// TestBuildNetworkPolicies validates the NetworkPolicies generated from the connections of the nodes.
+func TestBuildNetworkPolicies(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "default")
+
+	cfg := config.MutualPeersConfig{
+		Clusters: []config.Cluster{{Name: "remote", Namespace: "celestia"}},
+		MutualPeers: []*config.MutualPeer{
+			{Peers: []config.Peer{
+				{NodeName: "consensus-full-1-0", NodeType: "consensus", ConnectsAsEnvVar: true, ConnectsTo: []string{"consensus-validator-1"}},
+			}},
+			{Peers: []config.Peer{
+				{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsAsEnvVar: true, ConnectsTo: []string{"consensus-full-1"}},
+			}},
+			{Peers: []config.Peer{
+				{NodeName: "da-full-1-0", NodeType: "da", Namespace: "other", ConnectsTo: []string{
+					"da-bridge-1-0",
+					"/dns/da-bridge-1/tcp/2121/p2p/12D3KooWCNkYLnW5bNwXbrrz9cf3AmWq5UJKn7MymP6PHu3L6gYN",
+				}},
+			}},
+			{Peers: []config.Peer{
+				{NodeName: "da-light-1-0", NodeType: "da", Cluster: "remote", ConnectsTo: []string{"da-bridge-1-0", "da-full-2-0"}},
+			}},
+			{Peers: []config.Peer{
+				{NodeName: "da-light-2-0", NodeType: "da", Cluster: "remote", ExternalService: "da-light-2-lb", ConnectsTo: []string{"da-full-2-0"}},
+			}},
+			{Peers: []config.Peer{
+				{NodeName: "da-full-2-0", NodeType: "da", Cluster: "remote", ExternalService: "da-full-2-lb"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		cluster string
+		want    []policySummary
+	}{
+		{
+			name:    "Case 1: local cluster with a node that receives connections from other clusters",
+			cluster: "",
+			want: []policySummary{
+				{
+					name:      "torch-consensus-full-1-0",
+					namespace: "default",
+					rules: map[string][]string{
+						"statefulset.kubernetes.io/pod-name=da-bridge-1-0": {"TCP/26657", "TCP/9090"},
+						"app=torch": {"TCP/26657"},
+					},
+				},
+				{
+					name:      "torch-consensus-validator-1-0",
+					namespace: "default",
+					rules: map[string][]string{
+						"statefulset.kubernetes.io/pod-name=consensus-full-1-0": {"TCP/26656"},
+						"app=torch": {"TCP/26657"},
+					},
+				},
+				{
+					name:      "torch-da-bridge-1-0",
+					namespace: "default",
+					rules: map[string][]string{
+						"kubernetes.io/metadata.name=other/statefulset.kubernetes.io/pod-name=da-full-1-0": {"TCP/2121", "UDP/2121"},
+						"*": {"TCP/2121", "UDP/2121"},
+					},
+				},
+			},
+		},
+		{
+			name:    "Case 2: remote cluster with a node exposed through its Load Balancer",
+			cluster: "remote",
+			want: []policySummary{
+				{
+					name:      "torch-da-full-2-0",
+					namespace: "celestia",
+					rules: map[string][]string{
+						"statefulset.kubernetes.io/pod-name=da-light-1-0": {"TCP/2121", "UDP/2121"},
+						"statefulset.kubernetes.io/pod-name=da-light-2-0": {"TCP/2121", "UDP/2121"},
+						"*": {"TCP/2121", "UDP/2121"},
+					},
+				},
+			},
+		},
+		{
+			name:    "Case 3: cluster without nodes",
+			cluster: "unknown",
+			want:    []policySummary{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarizePolicies(BuildNetworkPolicies(cfg, tt.cluster))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildNetworkPolicies() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyNetworkPolicies validates that the NetworkPolicies are created, updated and deleted when they are not
+// generated anymore.
+func TestApplyNetworkPolicies(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "default")
+	// the NetworkPolicies that are not managed by Torch are never deleted.
+	client := fake.NewSimpleClientset(&networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "default"},
+	})
+	SetClientSet(client)
+	defer SetClientSet(nil)
+
+	peer := func(connectsTo ...string) config.MutualPeersConfig {
+		return config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{
+			{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: connectsTo},
+		}}}}
+	}
+
+	tests := []struct {
+		name      string
+		cfg       config.MutualPeersConfig
+		wantRules int
+		wantPorts int
+	}{
+		{
+			name:      "Case 1: the NetworkPolicy is created",
+			cfg:       peer("da-bridge-1-0"),
+			wantRules: 1,
+			wantPorts: 2,
+		},
+		{
+			name:      "Case 2: the NetworkPolicy is updated",
+			cfg:       peer("da-bridge-1-0", "da-bridge-1-0"),
+			wantRules: 2,
+			wantPorts: 2,
+		},
+		{
+			name: "Case 3: the NetworkPolicy of the connection removed from the config is deleted",
+			cfg:  peer(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SyncNetworkPolicies(context.Background(), tt.cfg); err != nil {
+				t.Fatalf("SyncNetworkPolicies() error = %v", err)
+			}
+
+			policy, err := client.NetworkingV1().NetworkPolicies("default").Get(context.Background(), "torch-da-bridge-1-0", metav1.GetOptions{})
+			if tt.wantRules == 0 {
+				if !errors.IsNotFound(err) {
+					t.Errorf("Get() error = %v, want the NetworkPolicy deleted", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if policy.Labels[managedByLabel] != managedByValue {
+				t.Errorf("labels = %v, want %s=%s", policy.Labels, managedByLabel, managedByValue)
+			}
+			if len(policy.Spec.Ingress) != tt.wantRules {
+				t.Errorf("ingress rules = %d, want %d", len(policy.Spec.Ingress), tt.wantRules)
+			}
+			if got := len(policy.Spec.Ingress[0].Ports); got != tt.wantPorts {
+				t.Errorf("ports = %d, want %d", got, tt.wantPorts)
+			}
+		})
+	}
+
+	if _, err := client.NetworkingV1().NetworkPolicies("default").Get(context.Background(), "deny-all", metav1.GetOptions{}); err != nil {
+		t.Errorf("Get() error = %v, want the NetworkPolicy not managed by Torch kept", err)
+	}
+}
+
+// TestNetworkPoliciesYAML validates the manifests of the NetworkPolicies.
+func TestNetworkPoliciesYAML(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "default")
+	policies := BuildNetworkPolicies(config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{
+		{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-bridge-1-0"}},
+		{NodeName: "da-full-2-0", NodeType: "da", ConnectsTo: []string{"da-bridge-2-0"}},
+	}}}}, "")
+
+	out, err := NetworkPoliciesYAML(policies)
+	if err != nil {
+		t.Fatalf("NetworkPoliciesYAML() error = %v", err)
+	}
+
+	manifests := string(out)
+	for _, want := range []string{
+		"kind: NetworkPolicy",
+		"apiVersion: networking.k8s.io/v1",
+		"name: torch-da-bridge-1-0",
+		"name: torch-da-bridge-2-0",
+		"protocol: " + string(corev1.ProtocolTCP),
+		"port: 2121",
+	} {
+		if !strings.Contains(manifests, want) {
+			t.Errorf("NetworkPoliciesYAML() doesn't contain [%s]:\n%s", want, manifests)
+		}
+	}
+	if got := strings.Count(manifests, yamlSeparator); got != 2 {
+		t.Errorf("documents = %d, want 2", got)
+	}
+}
@@ -0,0 +1,393 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+const (
+	reasonPodReady          = "PodReady"          // reasonPodReady reason of the tasks published when a pod becomes ready.
+	kindStatefulSet         = "StatefulSet"       // kindStatefulSet kind of the StatefulSet owners.
+	kindReplicaSet          = "ReplicaSet"        // kindReplicaSet kind of the ReplicaSet owners, created by the Deployments.
+	kindDeployment          = "Deployment"        // kindDeployment kind of the Deployment owners.
+	podTemplateHashLabel    = "pod-template-hash" // podTemplateHashLabel label added by the Deployments to the names of their ReplicaSets.
+	timeoutDurationDeletion = 30 * time.Second    // timeoutDurationDeletion max time to delete a node from the DB.
+)
+
+const (
+	annotationPrefix     = "torch.celestia.org/"            // annotationPrefix prefix of the annotations used by Torch.
+	NodeTypeAnnotation   = annotationPrefix + "node-type"   // NodeTypeAnnotation type of the node, da or consensus.
+	ConnectsToAnnotation = annotationPrefix + "connects-to" // ConnectsToAnnotation comma separated nodes or multi addresses to connect to.
+	ContainerAnnotation  = annotationPrefix + "container"   // ContainerAnnotation name of the main container of the node.
+)
+
+const (
+	PeerIDAnnotation        = annotationPrefix + "peer-id"        // PeerIDAnnotation id of the node generated by Torch.
+	MultiAddrAnnotation     = annotationPrefix + "multiaddr"      // MultiAddrAnnotation multi address used to connect to the node.
+	ConfigVersionAnnotation = annotationPrefix + "config-version" // ConfigVersionAnnotation version of the config used to set up the node.
+	InjectedAnnotation      = annotationPrefix + "injected"       // InjectedAnnotation added by the admission webhook to the pods with their connections injected.
+)
+
+const (
+	IPSourcePod  = "podIP"  // IPSourcePod the nodes are reached using the IPs of their pods.
+	IPSourceHost = "hostIP" // IPSourceHost the nodes are reached using the IP of the host where their pods run.
+)
+
+var (
+	// ErrContainerNotRunning is returned when none of the containers is running in the pod.
+	ErrContainerNotRunning = stderrors.New("container not running")
+	// ErrPodWithoutIP is returned when the pod doesn't have an IP assigned yet.
+	ErrPodWithoutIP = stderrors.New("pod without IP")
+	// ErrInvalidIPSource is returned when the source of the IPs is not podIP or hostIP.
+	ErrInvalidIPSource = stderrors.New("invalid IP source, must be podIP or hostIP")
+)
+
+// removeNode removes the node from the DB and its metrics, it is replaced in the tests.
+var removeNode = func(nodeName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationDeletion)
+	defer cancel()
+
+	if err := redis.DeleteNode(redis.InitRedisConfig(), ctx, nodeName); err != nil {
+		return err
+	}
+	metrics.UnregisterMetric(nodeName)
+	metrics.UnregisterExternalMultiAddr(nodeName)
+	return nil
+}
+
+// PodOwner returns the kind and the name of the workload that owns the pod, StatefulSet or Deployment,
+// it returns empty values if the pod is not owned by any of them.
+func PodOwner(pod *corev1.Pod) (string, string) {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+
+		switch owner.Kind {
+		case kindStatefulSet:
+			return kindStatefulSet, owner.Name
+		case kindReplicaSet:
+			// the ReplicaSets of a Deployment are named <deployment>-<pod-template-hash>.
+			if hash := pod.Labels[podTemplateHashLabel]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+				return kindDeployment, strings.TrimSuffix(owner.Name, "-"+hash)
+			}
+		}
+	}
+	return "", ""
+}
+
+// IsPodReady checks if the pod is running and its Ready condition is true.
+func IsPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// NewPodTask generates the task to process the pod, including the connections and the container declared in its
+// annotations.
+func NewPodTask(pod *corev1.Pod, nodeType string) redis.NodeTask {
+	return redis.NodeTask{
+		NodeName:        pod.Name,
+		Namespace:       pod.Namespace,
+		NodeType:        nodeType,
+		ResourceVersion: pod.ResourceVersion,
+		Reason:          reasonPodReady,
+		ContainerName:   pod.Annotations[ContainerAnnotation],
+		ConnectsTo:      ParseConnectsTo(pod.Annotations[ConnectsToAnnotation]),
+		Injected:        pod.Annotations[InjectedAnnotation] == "true",
+	}
+}
+
+// PodNodeType returns the type of node of the pod, empty if it is not a node managed by Torch.
+// The type declared in the annotation of the pod has priority over the name of the workload that owns it.
+func PodNodeType(pod *corev1.Pod) string {
+	if nodeType, ok := pod.Annotations[NodeTypeAnnotation]; ok {
+		nodeType = strings.TrimSpace(nodeType)
+		if nodeType != "da" && nodeType != "consensus" {
+			log.Warn("Invalid node type in the pod: [", pod.Name, "]: [", nodeType, "], skipping it...")
+			return ""
+		}
+		return nodeType
+	}
+
+	_, owner := PodOwner(pod)
+	if owner == "" {
+		return ""
+	}
+	return NodeTypeFromName(owner)
+}
+
+// ParseConnectsTo returns the nodes or multi addresses of the comma separated list.
+func ParseConnectsTo(value string) []string {
+	var connectsTo []string
+	for _, c := range strings.Split(value, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			connectsTo = append(connectsTo, c)
+		}
+	}
+	return connectsTo
+}
+
+// podHandler publishes a task for every pod of a node of the cluster when it becomes ready, and removes the nodes of
+// the pods that go away. The resyncs only publish the pods whose task couldn't be published, the nodes already
+// processed are kept in sync by the reconciler.
+func podHandler(stsLister appslisters.StatefulSetLister, cluster string) cache.ResourceEventHandlerFuncs {
+	var pending sync.Map // pending pods whose task couldn't be published, keyed by namespace/name.
+	publish := func(pod *corev1.Pod) {
+		nodeType := PodNodeType(pod)
+		if nodeType == "" {
+			return
+		}
+
+		log.Info("Pod ready: [", pod.Name, "]")
+		task := NewPodTask(pod, nodeType)
+		task.Cluster = cluster
+		// the node is checked again in the next resync if we couldn't publish it.
+		if err := produceTask(task, queueK8SNodes); err != nil {
+			log.Error("ERROR adding the node to the queue: [", pod.Name, "]: ", err)
+			pending.Store(pod.Namespace+"/"+pod.Name, true)
+			return
+		}
+		pending.Delete(pod.Namespace + "/" + pod.Name)
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok && IsPodReady(pod) {
+				publish(pod)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, okOld := oldObj.(*corev1.Pod)
+			newPod, okNew := newObj.(*corev1.Pod)
+			if !okOld || !okNew || !IsPodReady(newPod) {
+				return
+			}
+
+			// publish the pods that became ready, and the ones that couldn't be published on every resync.
+			if !IsPodReady(oldPod) {
+				publish(newPod)
+				return
+			}
+			if _, ok := pending.Load(newPod.Namespace + "/" + newPod.Name); ok && oldPod.ResourceVersion == newPod.ResourceVersion {
+				publish(newPod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || PodNodeType(pod) == "" {
+				return
+			}
+			pending.Delete(pod.Namespace + "/" + pod.Name)
+
+			if !isPodGone(pod, stsLister) {
+				log.Info("Pod deleted: [", pod.Name, "], it will be recreated, keeping its node")
+				return
+			}
+
+			log.Info("Pod removed: [", pod.Name, "], deleting its node")
+			if err := removeNode(pod.Name); err != nil {
+				log.Error("Error deleting the node: [", pod.Name, "]: ", err)
+			}
+		},
+	}
+}
+
+// isPodGone checks if the pod deleted is not going to be recreated with the same name, that happens with the pods of
+// the Deployments, and with the pods of the StatefulSets that were deleted or scaled down.
+func isPodGone(pod *corev1.Pod, stsLister appslisters.StatefulSetLister) bool {
+	kind, owner := PodOwner(pod)
+	if kind != kindStatefulSet {
+		return true
+	}
+
+	statefulSet, err := stsLister.StatefulSets(pod.Namespace).Get(owner)
+	if errors.IsNotFound(err) || (err == nil && statefulSet.DeletionTimestamp != nil) {
+		return true
+	}
+	if err != nil {
+		log.Error("Error getting the StatefulSet: [", owner, "]: ", err)
+		return false
+	}
+
+	return podOrdinal(pod.Name, owner) >= statefulSetReplicas(statefulSet)
+}
+
+// podOrdinal returns the ordinal of the pod in the StatefulSet, -1 if it is not valid.
+func podOrdinal(podName, statefulSetName string) int {
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(podName, statefulSetName+"-"))
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// statefulSetReplicas returns the number of replicas desired in the StatefulSet.
+func statefulSetReplicas(statefulSet *appsv1.StatefulSet) int {
+	if statefulSet.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*statefulSet.Spec.Replicas)
+}
+
+// GetRunningContainer returns the first container of the list that is running in the pod, init containers included.
+func GetRunningContainer(ctx context.Context, namespace, podName string, containers ...string) (string, error) {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		log.Error("Error getting the pod: [", podName, "]: ", err)
+		return "", err
+	}
+
+	return runningContainer(pod, containers...)
+}
+
+// runningContainer returns the first container of the list that is running in the pod.
+func runningContainer(pod *corev1.Pod, containers ...string) (string, error) {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, container := range containers {
+		for _, status := range statuses {
+			if status.Name == container && status.State.Running != nil {
+				return container, nil
+			}
+		}
+	}
+	return "", ErrContainerNotRunning
+}
+
+// GetPodAnnotation returns the value of the annotation of the pod, empty if the pod doesn't have it.
+func GetPodAnnotation(ctx context.Context, namespace, podName, key string) (string, error) {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return pod.Annotations[key], nil
+}
+
+// AnnotatePod sets the annotations in the pod, the pod is not patched when it already has them.
+func AnnotatePod(ctx context.Context, namespace, podName string, annotations map[string]string) error {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return err
+	}
+	return annotatePod(ctx, client, namespace, podName, annotations)
+}
+
+// annotatePod sets the annotations in the pod using a merge patch, so the rest of the annotations are kept.
+func annotatePod(
+	ctx context.Context,
+	client kubernetes.Interface,
+	namespace, podName string,
+	annotations map[string]string,
+) error {
+	pods := client.CoreV1().Pods(namespace)
+	pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for key, value := range annotations {
+		if current, ok := pod.Annotations[key]; !ok || current != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = pods.Patch(ctx, podName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+
+	log.Info("Pod [", podName, "] annotated: ", annotations)
+	return nil
+}
+
+// GetPodIPs returns the IPs of the pod, one per IP family, taken from the IPs of the pod (podIP, the default) or
+// from the IP of the host where the pod runs (hostIP).
+func GetPodIPs(ctx context.Context, namespace, podName, source string) ([]string, error) {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		log.Error("Error getting the pod: [", podName, "]: ", err)
+		return nil, err
+	}
+
+	return podIPs(pod, source)
+}
+
+// podIPs returns the IPs of the pod depending on the source.
+func podIPs(pod *corev1.Pod, source string) ([]string, error) {
+	var ips []string
+	switch source {
+	case "", IPSourcePod:
+		for _, podIP := range pod.Status.PodIPs {
+			ips = append(ips, podIP.IP)
+		}
+		// the pods created before the dual stack support only have the podIP.
+		if len(ips) == 0 && pod.Status.PodIP != "" {
+			ips = append(ips, pod.Status.PodIP)
+		}
+	case IPSourceHost:
+		if pod.Status.HostIP != "" {
+			ips = append(ips, pod.Status.HostIP)
+		}
+	default:
+		return nil, ErrInvalidIPSource
+	}
+
+	if len(ips) == 0 {
+		return nil, ErrPodWithoutIP
+	}
+	return ips, nil
+}
@@ -0,0 +1,258 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestPodOwner validates the owners of the pods.
+func TestPodOwner(t *testing.T) {
+	deploymentPod := newPod("da-light-7d9f-abcde", kindReplicaSet, "da-light-7d9f", true)
+	deploymentPod.Labels = map[string]string{podTemplateHashLabel: "7d9f"}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		wantKind string
+		wantName string
+	}{
+		{
+			name:     "Case 1: Pod owned by a StatefulSet",
+			pod:      newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true),
+			wantKind: kindStatefulSet,
+			wantName: "da-bridge-1",
+		},
+		{
+			name:     "Case 2: Pod owned by a Deployment",
+			pod:      deploymentPod,
+			wantKind: kindDeployment,
+			wantName: "da-light",
+		},
+		{
+			name: "Case 3: Pod owned by a ReplicaSet without Deployment",
+			pod:  newPod("da-light-abcde", kindReplicaSet, "da-light", true),
+		},
+		{
+			name: "Case 4: Pod owned by a Job",
+			pod:  newPod("da-job-abcde", "Job", "da-job", true),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, name := PodOwner(tt.pod)
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("PodOwner() = %v, %v, want %v, %v", kind, name, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
+
+// TestPodNodeType validates the type of node of the pods.
+func TestPodNodeType(t *testing.T) {
+	annotated := newPod("celestia-light-0", kindStatefulSet, "celestia-light", true)
+	annotated.Annotations = map[string]string{NodeTypeAnnotation: "da"}
+	invalid := newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true)
+	invalid.Annotations = map[string]string{NodeTypeAnnotation: "bridge"}
+	standalone := newPod("consensus-validator", "", "", true)
+	standalone.OwnerReferences = nil
+	standalone.Annotations = map[string]string{NodeTypeAnnotation: "consensus"}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want string
+	}{
+		{
+			name: "Case 1: Node type from the name of the StatefulSet",
+			pod:  newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true),
+			want: "da",
+		},
+		{
+			name: "Case 2: Workloads starting with the prefix are not nodes",
+			pod:  newPod("dashboard-0", kindStatefulSet, "dashboard", true),
+			want: "",
+		},
+		{
+			name: "Case 3: Node type from the annotation",
+			pod:  annotated,
+			want: "da",
+		},
+		{
+			name: "Case 4: Invalid node type in the annotation",
+			pod:  invalid,
+			want: "",
+		},
+		{
+			name: "Case 5: Pod without owner declaring its node type",
+			pod:  standalone,
+			want: "consensus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PodNodeType(tt.pod); got != tt.want {
+				t.Errorf("PodNodeType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseConnectsTo validates the list of connections declared by the pods.
+func TestParseConnectsTo(t *testing.T) {
+	got := ParseConnectsTo(" da-bridge-1-0, ,/dns/da-bridge-2/tcp/2121/p2p/12D3KooW,")
+	want := []string{"da-bridge-1-0", "/dns/da-bridge-2/tcp/2121/p2p/12D3KooW"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseConnectsTo() = %v, want %v", got, want)
+	}
+}
+
+// TestRunningContainer validates the container used to exec in the pods.
+func TestRunningContainer(t *testing.T) {
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	terminated := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+
+	initializing := &corev1.Pod{Status: corev1.PodStatus{
+		InitContainerStatuses: []corev1.ContainerStatus{{Name: "da-setup", State: running}},
+		ContainerStatuses:     []corev1.ContainerStatus{{Name: "da"}},
+	}}
+	started := &corev1.Pod{Status: corev1.PodStatus{
+		InitContainerStatuses: []corev1.ContainerStatus{{Name: "da-setup", State: terminated}},
+		ContainerStatuses:     []corev1.ContainerStatus{{Name: "da", State: running}},
+	}}
+
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Case 1: Setup container running",
+			pod:  initializing,
+			want: "da-setup",
+		},
+		{
+			name: "Case 2: Setup container finished, main container running",
+			pod:  started,
+			want: "da",
+		},
+		{
+			name:    "Case 3: No container running",
+			pod:     &corev1.Pod{},
+			wantErr: ErrContainerNotRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runningContainer(tt.pod, "da-setup", "da")
+			if err != tt.wantErr {
+				t.Fatalf("runningContainer() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("runningContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAnnotatePod validates that the annotations are added to the pod keeping the rest.
+func TestAnnotatePod(t *testing.T) {
+	pod := newPod("da-bridge-1-0", kindStatefulSet, "da-bridge-1", true)
+	pod.Annotations = map[string]string{NodeTypeAnnotation: "da"}
+	client := fake.NewSimpleClientset(pod)
+	ctx := context.Background()
+
+	annotations := map[string]string{PeerIDAnnotation: "12D3KooW", ConfigVersionAnnotation: "v1"}
+	if err := annotatePod(ctx, client, pod.Namespace, pod.Name, annotations); err != nil {
+		t.Fatalf("annotatePod() error = %v", err)
+	}
+
+	// Case 1: the annotations are added and the rest are kept.
+	got, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	want := map[string]string{NodeTypeAnnotation: "da", PeerIDAnnotation: "12D3KooW", ConfigVersionAnnotation: "v1"}
+	if !reflect.DeepEqual(got.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", got.Annotations, want)
+	}
+
+	// Case 2: the pod is not patched when it already has the annotations.
+	client.ClearActions()
+	if err := annotatePod(ctx, client, pod.Namespace, pod.Name, annotations); err != nil {
+		t.Fatalf("annotatePod() error = %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			t.Errorf("unexpected patch of the pod")
+		}
+	}
+}
+
+// TestPodIPs validates the IPs used to reach the nodes.
+func TestPodIPs(t *testing.T) {
+	dualStack := &corev1.Pod{Status: corev1.PodStatus{
+		PodIP:  "10.0.0.1",
+		PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}, {IP: "fd00::1"}},
+		HostIP: "192.168.0.1",
+	}}
+
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		source  string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "Case 1: IPs of the pod by default",
+			pod:    dualStack,
+			source: "",
+			want:   []string{"10.0.0.1", "fd00::1"},
+		},
+		{
+			name:   "Case 2: IP of the host",
+			pod:    dualStack,
+			source: IPSourceHost,
+			want:   []string{"192.168.0.1"},
+		},
+		{
+			name:   "Case 3: Pod with the podIP only",
+			pod:    &corev1.Pod{Status: corev1.PodStatus{PodIP: "10.0.0.2"}},
+			source: IPSourcePod,
+			want:   []string{"10.0.0.2"},
+		},
+		{
+			name:    "Case 4: Pod without IP",
+			pod:     &corev1.Pod{},
+			source:  IPSourcePod,
+			wantErr: ErrPodWithoutIP,
+		},
+		{
+			name:    "Case 5: Invalid source",
+			pod:     dualStack,
+			source:  "nodeIP",
+			wantErr: ErrInvalidIPSource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := podIPs(tt.pod, tt.source)
+			if err != tt.wantErr {
+				t.Fatalf("podIPs() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("podIPs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/jrmanes/torch/config"
+)
+
+const (
+	rbacRoleKind        = "Role"                   // rbacRoleKind kind of the Roles.
+	rbacRoleBindingKind = "RoleBinding"            // rbacRoleBindingKind kind of the RoleBindings.
+	rbacAPI             = rbacv1.GroupName + "/v1" // rbacAPI apiVersion of the Roles and the RoleBindings.
+)
+
+// rbacRecheckInterval how often the permissions are checked again.
+var rbacRecheckInterval = 1 * time.Minute
+
+// PermissionCheck represents the result of the check of a verb of a resource in a namespace.
+type PermissionCheck struct {
+	Cluster   string `json:"cluster,omitempty"` // Cluster where the permission is checked, empty for the cluster where Torch runs.
+	Namespace string `json:"namespace"`         // Namespace where the permission is checked.
+	Group     string `json:"group,omitempty"`   // Group API group of the resource.
+	Resource  string `json:"resource"`          // Resource name, including the subresource, e.g. pods/exec.
+	Verb      string `json:"verb"`              // Verb checked.
+	Allowed   bool   `json:"allowed"`           // Allowed true when Torch has the permission.
+	Reason    string `json:"reason,omitempty"`  // Reason returned by the authorizer.
+}
+
+// RBACStatus represents the result of the last RBAC self-check.
+type RBACStatus struct {
+	Checked bool              `json:"checked"`           // Checked true once the permissions were checked.
+	Missing []PermissionCheck `json:"missing,omitempty"` // Missing permissions that Torch doesn't have.
+	Error   string            `json:"error,omitempty"`   // Error of the last check, if any.
+}
+
+// Ready checks if all the permissions were checked and Torch has all of them.
+func (s RBACStatus) Ready() bool {
+	return s.Checked && s.Error == "" && len(s.Missing) == 0
+}
+
+var (
+	rbacStatus     RBACStatus   // rbacStatus result of the last RBAC self-check.
+	rbacStatusLock sync.RWMutex // rbacStatusLock protects the rbacStatus.
+)
+
+// RBACCheckEnabled checks if Torch has to check its permissions when it starts, it is disabled with RBAC_CHECK=false.
+func RBACCheckEnabled() bool {
+	return os.Getenv("RBAC_CHECK") != "false"
+}
+
+// GetRBACStatus returns the result of the last RBAC self-check, it is always ready when the check is disabled.
+func GetRBACStatus() RBACStatus {
+	if !RBACCheckEnabled() {
+		return RBACStatus{Checked: true}
+	}
+
+	rbacStatusLock.RLock()
+	defer rbacStatusLock.RUnlock()
+	return rbacStatus
+}
+
+// setRBACStatus stores the result of the RBAC self-check.
+func setRBACStatus(status RBACStatus) {
+	rbacStatusLock.Lock()
+	defer rbacStatusLock.Unlock()
+	rbacStatus = status
+}
+
+// RBACRules returns the rules that Torch needs in every namespace of the cluster: the namespace watched in the cluster
+// and the namespaces of its nodes. The rules of the optional features are only included when they are used: the
+// Leases of the leader election, the NetworkPolicies, the ConfigMaps and the Secrets of the deliveries and the Secrets
+// with the kubeconfig of the remote clusters.
+func RBACRules(cfg config.MutualPeersConfig, cluster string) map[string][]rbacv1.PolicyRule {
+	watched := configClusterNamespace(cfg, cluster)
+	namespaces := map[string]bool{watched: true}
+	deliveries := map[string]bool{}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if peer.Cluster != cluster {
+				continue
+			}
+			if peer.Namespace != "" {
+				namespaces[peer.Namespace] = true
+			}
+			deliveries[peer.Delivery.Mode] = true
+		}
+	}
+
+	rules := map[string][]rbacv1.PolicyRule{}
+	for namespace := range namespaces {
+		rules[namespace] = nodeRules(cluster, deliveries)
+	}
+
+	// the Lease and the kubeconfig of the remote clusters are in the namespace of Torch.
+	if cluster == "" {
+		torchNamespace := GetCurrentNamespace()
+		if LeaderElectionEnabled() {
+			rules[torchNamespace] = addRule(rules[torchNamespace], coordinationv1.GroupName, "leases", "get", "create", "update")
+		}
+		for _, c := range cfg.Clusters {
+			if c.Secret != "" {
+				rules[torchNamespace] = addRule(rules[torchNamespace], "", "secrets", "get")
+			}
+		}
+	}
+	return rules
+}
+
+// nodeRules returns the rules that Torch needs in the namespaces of the nodes.
+func nodeRules(cluster string, deliveries map[string]bool) []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	rules = addRule(rules, "", "pods", "get", "list", "watch", "patch")
+	rules = addRule(rules, "", "pods/exec", "create")
+	rules = addRule(rules, "", "services", "get", "list", "watch")
+	rules = addRule(rules, "apps", "statefulsets", "get", "list", "watch")
+	// the events are only recorded in the cluster where Torch runs.
+	if cluster == "" {
+		rules = addRule(rules, "", "events", "create", "patch")
+	}
+	if deliveries[DeliveryConfigMap] {
+		rules = addRule(rules, "", "configmaps", "get", "create", "update")
+	}
+	if deliveries[DeliverySecret] {
+		rules = addRule(rules, "", "secrets", "get", "create", "update")
+	}
+	if NetworkPoliciesEnabled() {
+		rules = addRule(rules, networkingv1.GroupName, "networkpolicies", "get", "list", "create", "update", "delete")
+	}
+	return rules
+}
+
+// addRule adds the verbs of the resource to the rules, merging them with the rule of the resource if it exists.
+func addRule(rules []rbacv1.PolicyRule, group, resource string, verbs ...string) []rbacv1.PolicyRule {
+	for i, rule := range rules {
+		if rule.APIGroups[0] != group || rule.Resources[0] != resource {
+			continue
+		}
+		for _, verb := range verbs {
+			if !containsString(rule.Verbs, verb) {
+				rules[i].Verbs = append(rules[i].Verbs, verb)
+			}
+		}
+		return rules
+	}
+	return append(rules, rbacv1.PolicyRule{APIGroups: []string{group}, Resources: []string{resource}, Verbs: verbs})
+}
+
+// containsString checks if the value is in the list.
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRBAC checks with SelfSubjectAccessReviews every verb of the rules that Torch needs, in the cluster where Torch
+// runs and in the remote clusters.
+func CheckRBAC(ctx context.Context, cfg config.MutualPeersConfig) ([]PermissionCheck, error) {
+	var checks []PermissionCheck
+	for _, cluster := range append([]string{""}, ClusterNames()...) {
+		client, err := GetClusterClientSet(cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		rules := RBACRules(cfg, cluster)
+		for _, namespace := range sortedKeys(rules) {
+			for _, rule := range rules[namespace] {
+				for _, verb := range rule.Verbs {
+					check, err := checkPermission(ctx, client, cluster, namespace, rule.APIGroups[0], rule.Resources[0], verb)
+					if err != nil {
+						log.Error("Error checking the permission [", verb, " ", rule.Resources[0], "] in the namespace: [", namespace, "]: ", err)
+						return nil, err
+					}
+					checks = append(checks, check)
+				}
+			}
+		}
+	}
+	return checks, nil
+}
+
+// checkPermission checks the verb of the resource in the namespace with a SelfSubjectAccessReview.
+func checkPermission(
+	ctx context.Context,
+	client kubernetes.Interface,
+	cluster, namespace, group, resource, verb string,
+) (PermissionCheck, error) {
+	name, subresource, _ := strings.Cut(resource, "/")
+	review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        verb,
+				Group:       group,
+				Resource:    name,
+				Subresource: subresource,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return PermissionCheck{}, err
+	}
+
+	return PermissionCheck{
+		Cluster:   cluster,
+		Namespace: namespace,
+		Group:     group,
+		Resource:  resource,
+		Verb:      verb,
+		Allowed:   review.Status.Allowed,
+		Reason:    review.Status.Reason,
+	}, nil
+}
+
+// MissingPermissions returns the checks of the permissions that Torch doesn't have.
+func MissingPermissions(checks []PermissionCheck) []PermissionCheck {
+	var missing []PermissionCheck
+	for _, check := range checks {
+		if !check.Allowed {
+			missing = append(missing, check)
+		}
+	}
+	return missing
+}
+
+// RunRBACCheck checks the permissions of Torch and stores the result, it is checked again every minute until the
+// context is done, so the readiness recovers once the Role is fixed and follows the permissions removed later.
+func RunRBACCheck(ctx context.Context, cfg config.MutualPeersConfig) error {
+	for {
+		checks, err := CheckRBAC(ctx, cfg)
+		if err != nil {
+			setRBACStatus(RBACStatus{Checked: true, Error: err.Error()})
+			return err
+		}
+
+		missing := MissingPermissions(checks)
+		setRBACStatus(RBACStatus{Checked: true, Missing: missing})
+		log.Info("RBAC self-check: [", len(checks), "] permissions checked, [", len(missing), "] missing")
+		for _, check := range missing {
+			log.Error("Missing RBAC permission: [", check.Verb, " ", check.Resource, "] in the namespace: [",
+				check.Namespace, "], cluster: [", check.Cluster, "]")
+		}
+		if len(missing) > 0 {
+			log.Error("Run `torch doctor rbac` to generate the Role and the RoleBinding that Torch needs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(rbacRecheckInterval):
+		}
+	}
+}
+
+// WriteRBACReport writes the result of the checks as a table.
+func WriteRBACReport(w io.Writer, checks []PermissionCheck) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tVERB\tRESOURCE\tRESULT")
+	for _, check := range checks {
+		cluster := check.Cluster
+		if cluster == "" {
+			cluster = "-"
+		}
+		resource := check.Resource
+		if check.Group != "" {
+			resource += "." + check.Group
+		}
+		result := "allowed"
+		if !check.Allowed {
+			result = "MISSING"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", cluster, check.Namespace, check.Verb, resource, result)
+	}
+	return tw.Flush()
+}
+
+// BuildRBAC returns the Roles with the rules that Torch needs in every namespace of the cluster, and the RoleBindings
+// that grant them to the ServiceAccount.
+func BuildRBAC(
+	cfg config.MutualPeersConfig,
+	cluster, name, serviceAccount, serviceAccountNamespace string,
+) ([]rbacv1.Role, []rbacv1.RoleBinding) {
+	rules := RBACRules(cfg, cluster)
+	roles := make([]rbacv1.Role, 0, len(rules))
+	bindings := make([]rbacv1.RoleBinding, 0, len(rules))
+	for _, namespace := range sortedKeys(rules) {
+		meta := metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{managedByLabel: managedByValue},
+		}
+		roles = append(roles, rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{Kind: rbacRoleKind, APIVersion: rbacAPI},
+			ObjectMeta: meta,
+			Rules:      rules[namespace],
+		})
+		bindings = append(bindings, rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{Kind: rbacRoleBindingKind, APIVersion: rbacAPI},
+			ObjectMeta: meta,
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      serviceAccount,
+				Namespace: serviceAccountNamespace,
+			}},
+			RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: rbacRoleKind, Name: name},
+		})
+	}
+	return roles, bindings
+}
+
+// RBACYAML returns the Roles and the RoleBindings as YAML documents.
+func RBACYAML(roles []rbacv1.Role, bindings []rbacv1.RoleBinding) ([]byte, error) {
+	objects := make([]interface{}, 0, len(roles)+len(bindings))
+	for i := range roles {
+		objects = append(objects, roles[i], bindings[i])
+	}
+	return manifestsYAML(objects)
+}
+
+// sortedKeys returns the keys of the rules sorted.
+func sortedKeys(rules map[string][]rbacv1.PolicyRule) []string {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,255 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/jrmanes/torch/config"
+)
+
+// ruleResources returns the resources of the rules with their verbs.
+func ruleResources(rules []rbacv1.PolicyRule) map[string]string {
+	resources := map[string]string{}
+	for _, rule := range rules {
+		resources[rule.Resources[0]] = strings.Join(rule.Verbs, ",")
+	}
+	return resources
+}
+
+// TestRBACRules validates the rules that Torch needs depending on the config and the features enabled.
+func TestRBACRules(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "default")
+	nodeResources := map[string]string{
+		"pods":         "get,list,watch,patch",
+		"pods/exec":    "create",
+		"services":     "get,list,watch",
+		"statefulsets": "get,list,watch",
+		"events":       "create,patch",
+	}
+	withResources := func(extra map[string]string) map[string]string {
+		resources := map[string]string{}
+		for k, v := range nodeResources {
+			resources[k] = v
+		}
+		for k, v := range extra {
+			resources[k] = v
+		}
+		return resources
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.MutualPeersConfig
+		cluster string
+		env     map[string]string
+		want    map[string]map[string]string
+	}{
+		{
+			name: "Case 1: nodes in the namespace of Torch",
+			cfg: config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{
+				{NodeName: "da-bridge-1-0", NodeType: "da"},
+			}}}},
+			want: map[string]map[string]string{"default": nodeResources},
+		},
+		{
+			name: "Case 2: nodes in other namespaces with ConfigMap delivery",
+			cfg: config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{
+				{NodeName: "da-bridge-1-0", NodeType: "da", Namespace: "celestia", Delivery: config.Delivery{Mode: DeliveryConfigMap}},
+			}}}},
+			want: map[string]map[string]string{
+				"default":  withResources(map[string]string{"configmaps": "get,create,update"}),
+				"celestia": withResources(map[string]string{"configmaps": "get,create,update"}),
+			},
+		},
+		{
+			name: "Case 3: leader election, NetworkPolicies and clusters with Secrets",
+			cfg: config.MutualPeersConfig{
+				Clusters: []config.Cluster{{Name: "east", Secret: "torch-east"}},
+			},
+			env: map[string]string{"LEADER_ELECTION": "true", "NETWORK_POLICIES": "true"},
+			want: map[string]map[string]string{
+				"default": withResources(map[string]string{
+					"networkpolicies": "get,list,create,update,delete",
+					"leases":          "get,create,update",
+					"secrets":         "get",
+				}),
+			},
+		},
+		{
+			name: "Case 4: remote cluster without events",
+			cfg: config.MutualPeersConfig{
+				Clusters: []config.Cluster{{Name: "east", Namespace: "celestia"}},
+			},
+			cluster: "east",
+			want: map[string]map[string]string{
+				"celestia": {
+					"pods":         "get,list,watch,patch",
+					"pods/exec":    "create",
+					"services":     "get,list,watch",
+					"statefulsets": "get,list,watch",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got := map[string]map[string]string{}
+			for namespace, rules := range RBACRules(tt.cfg, tt.cluster) {
+				got[namespace] = ruleResources(rules)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RBACRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunRBACCheck validates the permissions missing reported by the self-check.
+func TestRunRBACCheck(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "default")
+	cfg := config.MutualPeersConfig{}
+
+	tests := []struct {
+		name        string
+		denied      string
+		wantMissing []string
+		wantReady   bool
+	}{
+		{
+			name:      "Case 1: all the permissions granted",
+			wantReady: true,
+		},
+		{
+			name:        "Case 2: remote commands denied",
+			denied:      "exec",
+			wantMissing: []string{"create pods/exec"},
+		},
+	}
+
+	defer SetClientSet(nil)
+	defer setRBACStatus(RBACStatus{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "selfsubjectaccessreviews",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+					review.Status.Allowed = review.Spec.ResourceAttributes.Subresource != tt.denied || tt.denied == ""
+					return true, review, nil
+				})
+			SetClientSet(client)
+
+			// the context is done, so the check returns instead of waiting for the next recheck.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := RunRBACCheck(ctx, cfg); err != nil {
+				t.Fatalf("RunRBACCheck() error = %v", err)
+			}
+
+			status := GetRBACStatus()
+			var missing []string
+			for _, check := range status.Missing {
+				missing = append(missing, check.Verb+" "+check.Resource)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", missing, tt.wantMissing)
+			}
+			if status.Ready() != tt.wantReady {
+				t.Errorf("Ready() = %v, want %v", status.Ready(), tt.wantReady)
+			}
+		})
+	}
+}
+
+// TestRunRBACCheckRecheck validates that the permissions are checked again after all of them were granted.
+func TestRunRBACCheckRecheck(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "default")
+	interval := rbacRecheckInterval
+	rbacRecheckInterval = 10 * time.Millisecond
+	defer func() { rbacRecheckInterval = interval }()
+	defer SetClientSet(nil)
+	defer setRBACStatus(RBACStatus{})
+
+	var denied atomic.Bool
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = !denied.Load() || review.Spec.ResourceAttributes.Subresource != "exec"
+			return true, review, nil
+		})
+	SetClientSet(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- RunRBACCheck(ctx, config.MutualPeersConfig{})
+	}()
+
+	// Case 1: all the permissions are granted.
+	waitForRBACStatus(t, true)
+
+	// Case 2: the permission removed later is reported in the next check.
+	denied.Store(true)
+	waitForRBACStatus(t, false)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunRBACCheck() error = %v", err)
+	}
+}
+
+// waitForRBACStatus waits until the readiness of the RBAC status is the one expected.
+func waitForRBACStatus(t *testing.T, ready bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for GetRBACStatus().Ready() != ready {
+		if time.Now().After(deadline) {
+			t.Fatalf("Ready() = %v, want %v", !ready, ready)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestWriteRBACReport validates the report of the permissions.
+func TestWriteRBACReport(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteRBACReport(&out, []PermissionCheck{
+		{Namespace: "default", Resource: "pods/exec", Verb: "create", Allowed: true},
+		{Cluster: "east", Namespace: "celestia", Group: "apps", Resource: "statefulsets", Verb: "watch"},
+	})
+	if err != nil {
+		t.Fatalf("WriteRBACReport() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := [][]string{
+		{"CLUSTER", "NAMESPACE", "VERB", "RESOURCE", "RESULT"},
+		{"-", "default", "create", "pods/exec", "allowed"},
+		{"east", "celestia", "watch", "statefulsets.apps", "MISSING"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("WriteRBACReport() = %q, want %d lines", out.String(), len(want))
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("line %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
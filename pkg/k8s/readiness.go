@@ -0,0 +1,141 @@
+package k8s
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultContainerReadyTimeout = 30 * time.Second // defaultContainerReadyTimeout max time to wait for a container when CONTAINER_READY_TIMEOUT is empty.
+	containerReadyPollInterval   = time.Second      // containerReadyPollInterval how often the state of the container is checked.
+)
+
+// ErrContainerNotReady is returned when the container where a remote command has to run is not running.
+var ErrContainerNotReady = stderrors.New("container not ready")
+
+// GetContainerReadyTimeout returns the max time to wait for the container of a remote command to be running, it can
+// be changed with CONTAINER_READY_TIMEOUT.
+func GetContainerReadyTimeout() time.Duration {
+	timeout := os.Getenv("CONTAINER_READY_TIMEOUT")
+	if timeout == "" {
+		return defaultContainerReadyTimeout
+	}
+
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d <= 0 {
+		log.Error("Invalid CONTAINER_READY_TIMEOUT [", timeout, "], using the default value: ", defaultContainerReadyTimeout)
+		return defaultContainerReadyTimeout
+	}
+	return d
+}
+
+// WaitForContainer waits until the container is running in the pod of the cluster of the context. It returns
+// ErrContainerNotReady when the container isn't running before the timeout, or right away when it won't run anymore:
+// the init containers that already finished, the containers that are not in the pod and the pods that are being
+// deleted or that already finished. The empty container is the first container of the pod.
+func WaitForContainer(ctx context.Context, namespace, podName, container string) error {
+	client, err := clientSetFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	reason := ""
+	final := false
+	err = wait.PollUntilContextTimeout(ctx, containerReadyPollInterval, GetContainerReadyTimeout(), true,
+		func(ctx context.Context) (bool, error) {
+			pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				reason = "the pod doesn't exist"
+				return false, nil
+			}
+			if err != nil {
+				// the errors of the API are retried until the timeout.
+				reason = err.Error()
+				return false, nil
+			}
+
+			var running bool
+			running, reason, final = containerStatus(pod, container)
+			return running || final, nil
+		})
+	if err == nil && !final {
+		return nil
+	}
+
+	log.Warn("The container [", container, "] is not ready in the pod [", podName, "]: ", reason)
+	return fmt.Errorf("%w: container [%s] in the pod [%s]: %s", ErrContainerNotReady, container, podName, reason)
+}
+
+// containerStatus returns true when the container is running in the pod, otherwise the reason, and final is true when
+// the container won't run anymore, so there is no need to wait for it.
+func containerStatus(pod *corev1.Pod, container string) (running bool, reason string, final bool) {
+	if pod.DeletionTimestamp != nil {
+		return false, "the pod is being deleted", true
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false, "the pod has finished", true
+	}
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != container {
+			continue
+		}
+		switch {
+		case status.State.Running != nil:
+			return true, "", false
+		case status.State.Terminated != nil:
+			return false, "the init container has already finished", true
+		}
+		return false, "the init container is waiting: " + waitingReason(status), false
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != container {
+			continue
+		}
+		switch {
+		case status.State.Running != nil:
+			return true, "", false
+		case status.State.Terminated != nil:
+			return false, "the container has terminated", false
+		}
+		return false, "the container is waiting: " + waitingReason(status), false
+	}
+
+	if !hasContainer(pod, container) {
+		return false, "the container doesn't exist in the pod", true
+	}
+	return false, "the container hasn't started yet", false
+}
+
+// waitingReason returns the reason why the container is waiting.
+func waitingReason(status corev1.ContainerStatus) string {
+	if status.State.Waiting == nil || status.State.Waiting.Reason == "" {
+		return "unknown"
+	}
+	return status.State.Waiting.Reason
+}
+
+// hasContainer checks if the container is declared in the pod, init containers included.
+func hasContainer(pod *corev1.Pod, container string) bool {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			if c.Name == container {
+				return true
+			}
+		}
+	}
+	return false
+}
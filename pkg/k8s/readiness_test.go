@@ -0,0 +1,119 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// execPod returns a running pod in the default namespace, with the init container finished and the container running.
+func execPod(name, container, initContainer string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: initContainer}},
+			Containers:     []corev1.Container{{Name: container}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  initContainer,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  container,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		},
+	}
+}
+
+// TestContainerStatus validates the states of the containers where the remote commands can run.
+func TestContainerStatus(t *testing.T) {
+	running := execPod("da-bridge-1-0", "da", "da-setup")
+
+	initRunning := execPod("da-bridge-1-0", "da", "da-setup")
+	initRunning.Status.InitContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	initRunning.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"},
+	}
+
+	pending := execPod("da-bridge-1-0", "da", "da-setup")
+	pending.Status = corev1.PodStatus{Phase: corev1.PodPending}
+
+	deleting := execPod("da-bridge-1-0", "da", "da-setup")
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	finished := execPod("da-bridge-1-0", "da", "da-setup")
+	finished.Status.Phase = corev1.PodSucceeded
+
+	tests := []struct {
+		name        string
+		pod         *corev1.Pod
+		container   string
+		wantRunning bool
+		wantFinal   bool
+	}{
+		{name: "Case 1: Container running", pod: running, container: "da", wantRunning: true},
+		{name: "Case 2: First container of the pod", pod: running, container: "", wantRunning: true},
+		{name: "Case 3: Init container running", pod: initRunning, container: "da-setup", wantRunning: true},
+		{name: "Case 4: Container waiting for the init containers", pod: initRunning, container: "da"},
+		{name: "Case 5: Init container finished", pod: running, container: "da-setup", wantFinal: true},
+		{name: "Case 6: Container not started yet", pod: pending, container: "da"},
+		{name: "Case 7: Container not in the pod", pod: running, container: "celestia", wantFinal: true},
+		{name: "Case 8: Pod being deleted", pod: deleting, container: "da", wantFinal: true},
+		{name: "Case 9: Pod finished", pod: finished, container: "da", wantFinal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running, reason, final := containerStatus(tt.pod, tt.container)
+			if running != tt.wantRunning || final != tt.wantFinal {
+				t.Errorf("containerStatus() = %v, %v, %q, want %v, %v", running, final, reason, tt.wantRunning, tt.wantFinal)
+			}
+		})
+	}
+}
+
+// TestWaitForContainer validates that Torch waits for the containers that are starting.
+func TestWaitForContainer(t *testing.T) {
+	t.Setenv("CONTAINER_READY_TIMEOUT", "3s")
+	ctx := context.Background()
+
+	pending := execPod("da-bridge-1-0", "da", "da-setup")
+	pending.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"},
+	}
+	client := fake.NewSimpleClientset(pending)
+	SetClientSet(client)
+	defer SetClientSet(nil)
+
+	// Case 1: the init container already finished, there is no need to wait for it.
+	start := time.Now()
+	if err := WaitForContainer(ctx, "default", "da-bridge-1-0", "da-setup"); !errors.Is(err, ErrContainerNotReady) {
+		t.Errorf("WaitForContainer() error = %v, want %v", err, ErrContainerNotReady)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitForContainer() waited %v for a container that finished", elapsed)
+	}
+
+	// Case 2: the container starts while Torch is waiting for it.
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		_, _ = client.CoreV1().Pods("default").UpdateStatus(ctx, execPod("da-bridge-1-0", "da", "da-setup"), metav1.UpdateOptions{})
+	}()
+	if err := WaitForContainer(ctx, "default", "da-bridge-1-0", "da"); err != nil {
+		t.Errorf("WaitForContainer() error = %v, want <nil>", err)
+	}
+
+	// Case 3: the pod doesn't exist before the timeout.
+	t.Setenv("CONTAINER_READY_TIMEOUT", "1s")
+	if err := WaitForContainer(ctx, "default", "da-bridge-2-0", "da"); !errors.Is(err, ErrContainerNotReady) {
+		t.Errorf("WaitForContainer() error = %v, want %v", err, ErrContainerNotReady)
+	}
+}
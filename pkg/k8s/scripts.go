@@ -8,26 +8,17 @@ var (
 	trustedPeerFile          = "/tmp/TP-ADDR"
 	trustedPeerFileConsensus = "/home/celestia/config/TP-ADDR"
 	trustedPeerFileDA        = "/tmp/CONSENSUS_NODE_SERVICE"
-	nodeIpFile               = "/tmp/NODE_IP"
-	cmd                      = `$(ifconfig | grep -oE 'inet addr:([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)' | grep -v '127.0.0.1' | awk '{print substr($2, 6)}')`
-	trustedPeerPrefix        = "/ip4/" + cmd + "/tcp/2121/p2p/"
 )
 
-// CreateFileWithEnvVar creates the file in the FS with the node to connect.
-func CreateFileWithEnvVar(nodeToFile, nodeType string) []string {
-	f := ""
-	if nodeType == "consensus" {
-		f = trustedPeerFileConsensus
+// EnvVarFilePath returns the path of the file with the node to connect for the nodes that connect using env vars.
+func EnvVarFilePath(nodeType string) string {
+	switch nodeType {
+	case "consensus":
+		return trustedPeerFileConsensus
+	case "da":
+		return trustedPeerFileDA
 	}
-	if nodeType == "da" {
-		f = trustedPeerFileDA
-	}
-
-	script := fmt.Sprintf(`
-#!/bin/sh
-echo -n "%[2]s" > "%[1]s"`, f, nodeToFile)
-
-	return []string{"sh", "-c", script}
+	return ""
 }
 
 // CreateTrustedPeerCommand generates the command for creating trusted peers.
@@ -51,27 +42,27 @@ TP_ADDR=$(wget --header="Authorization: Bearer $AUTHTOKEN" \
 
 echo -n "${TP_ADDR}" >> "%[1]s"
 cat "%[1]s"
-`, trustedPeerFile, trustedPeerPrefix)
+`, trustedPeerFile)
 
 	return []string{"sh", "-c", script}
 }
 
-// GetNodeIP adds the node IP to a file.
-func GetNodeIP() []string {
-	script := fmt.Sprintf(`
-#!/bin/sh
-echo -n "%[2]s" > "%[1]s"
-cat "%[1]s"`, nodeIpFile, trustedPeerPrefix)
+// TeeFile writes the stdin of the command into the file, the path is passed as an argument so it doesn't need a shell.
+func TeeFile(file string) []string {
+	return []string{"tee", "--", file}
+}
 
-	return []string{"sh", "-c", script}
+// MoveFile renames the file src to dst, replacing dst if it exists.
+func MoveFile(src, dst string) []string {
+	return []string{"mv", "-f", "--", src, dst}
 }
 
-// WriteToFile writes content into a file.
-func WriteToFile(content, file string) []string {
-	script := fmt.Sprintf(`
-#!/bin/sh
-echo -n "%[1]s" > "%[2]s"
-cat "%[2]s"`, content, file)
+// ChecksumFile returns the sha256 checksum of the file.
+func ChecksumFile(file string) []string {
+	return []string{"sha256sum", "--", file}
+}
 
-	return []string{"sh", "-c", script}
+// ReadFile reads the content of a file.
+func ReadFile(file string) []string {
+	return []string{"cat", "--", file}
 }
@@ -8,43 +8,34 @@ import (
 // case1 common message.
 const case1 = "Case 1: Successfully script generated."
 
-// TestCreateFileWithEnvVar validates the node types and their path
-func TestCreateFileWithEnvVar(t *testing.T) {
-	type args struct {
-		nodeToFile string
-		nodeType   string
-	}
+// TestEnvVarFilePath validates the node types and their path
+func TestEnvVarFilePath(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
-		want []string
+		name     string
+		nodeType string
+		want     string
 	}{
 		{
-			name: "Case 1: Check [consensus] nodes",
-			args: args{
-				nodeToFile: "/home/celestia/config/TP-ADDR",
-				nodeType:   "consensus",
-			},
-			want: []string{"sh", "-c", `
-#!/bin/sh
-echo -n "/home/celestia/config/TP-ADDR" > "/home/celestia/config/TP-ADDR"`},
+			name:     "Case 1: Check [consensus] nodes",
+			nodeType: "consensus",
+			want:     "/home/celestia/config/TP-ADDR",
 		},
 		{
-			name: "Case 2: Check [da] nodes",
-			args: args{
-				nodeToFile: "/tmp/CONSENSUS_NODE_SERVICE",
-				nodeType:   "da",
-			},
-			want: []string{"sh", "-c", `
-#!/bin/sh
-echo -n "/tmp/CONSENSUS_NODE_SERVICE" > "/tmp/CONSENSUS_NODE_SERVICE"`},
+			name:     "Case 2: Check [da] nodes",
+			nodeType: "da",
+			want:     "/tmp/CONSENSUS_NODE_SERVICE",
+		},
+		{
+			name:     "Case 3: Unknown node type",
+			nodeType: "other",
+			want:     "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := CreateFileWithEnvVar(tt.args.nodeToFile, tt.args.nodeType); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("CreateFileWithEnvVar() = got: \n%v, \nwant \n%v", got, tt.want)
+			if got := EnvVarFilePath(tt.nodeType); got != tt.want {
+				t.Errorf("EnvVarFilePath() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -87,67 +78,44 @@ cat "/tmp/TP-ADDR"
 	}
 }
 
-// TestGetNodeIP gets the IP of the node and add it to a file
-func TestGetNodeIP(t *testing.T) {
+// TestFileCommands validates that the paths are passed as arguments, without a shell.
+func TestFileCommands(t *testing.T) {
+	file := `/tmp/"$(rm -rf /)"`
 	tests := []struct {
 		name string
+		got  []string
 		want []string
 	}{
 		{
-			name: case1,
-			want: []string{"sh", "-c", `
-#!/bin/sh
-echo -n "/ip4/$(ifconfig | grep -oE 'inet addr:([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)' | grep -v '127.0.0.1' | awk '{print substr($2, 6)}')/tcp/2121/p2p/" > "/tmp/NODE_IP"
-cat "/tmp/NODE_IP"`},
+			name: "Case 1: Write the stdin into the file",
+			got:  TeeFile(file),
+			want: []string{"tee", "--", file},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := GetNodeIP(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetNodeIP() = got: \n%v, \nwant: \n%v", got, tt.want)
-			}
-		})
-	}
-}
-
-// TestWriteToFile writes content to a file
-func TestWriteToFile(t *testing.T) {
-	type args struct {
-		content string
-		file    string
-	}
-	tests := []struct {
-		name string
-		args args
-		want []string
-	}{
 		{
-			name: case1,
-			args: args{
-				content: "THIS IS A TEST",
-				file:    "/tmp/test_file",
-			},
-			want: []string{"sh", "-c", `
-#!/bin/sh
-echo -n "THIS IS A TEST" > "/tmp/test_file"
-cat "/tmp/test_file"`},
+			name: "Case 2: Rename the file",
+			got:  MoveFile(file+".tmp", file),
+			want: []string{"mv", "-f", "--", file + ".tmp", file},
 		},
 		{
-			name: "Case 2: Successfully script generated.",
-			args: args{
-				content: "content in file",
-				file:    "/tmp/file_with_content",
-			},
-			want: []string{"sh", "-c", `
-#!/bin/sh
-echo -n "content in file" > "/tmp/file_with_content"
-cat "/tmp/file_with_content"`},
+			name: "Case 3: Checksum of the file",
+			got:  ChecksumFile(file),
+			want: []string{"sha256sum", "--", file},
+		},
+		{
+			name: "Case 4: Read the file",
+			got:  ReadFile(file),
+			want: []string{"cat", "--", file},
+		},
+		{
+			name: "Case 5: Read a file that starts with a dash",
+			got:  ReadFile("-n"),
+			want: []string{"cat", "--", "-n"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := WriteToFile(tt.args.content, tt.args.file); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("WriteToFile() = %v, want %v", got, tt.want)
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got = %v, want %v", tt.got, tt.want)
 			}
 		})
 	}
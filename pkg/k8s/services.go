@@ -3,145 +3,271 @@ package k8s
 import (
 	"context"
 	"errors"
-	"fmt"
+	"sort"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/jrmanes/torch/pkg/metrics"
 )
 
-// RetrieveAndGenerateMetrics retrieves the list of Load Balancers and generates metrics
-func RetrieveAndGenerateMetrics() ([]metrics.LoadBalancer, error) {
-	log.Info("Retrieving the list of Load Balancers")
+const (
+	EndpointLoadBalancer = "LoadBalancer" // EndpointLoadBalancer address published by the Load Balancer of the service.
+	EndpointNodePort     = "NodePort"     // EndpointNodePort port opened in every node of the cluster.
+	EndpointExternalIP   = "ExternalIP"   // EndpointExternalIP external IP assigned to the service.
+)
 
-	// Get list of LBs
-	svc, err := ListServices()
-	if err != nil {
-		log.Error("Failed to retrieve the LoadBalancers: ", err)
-		return nil, err
+// ExternalEndpoint represents an address where a service is reachable from outside the cluster.
+type ExternalEndpoint struct {
+	Cluster     string // Cluster of the service, empty for the cluster where Torch runs.
+	ServiceName string // ServiceName name of the service.
+	Namespace   string // Namespace of the service.
+	Type        string // Type of the endpoint: LoadBalancer, NodePort or ExternalIP.
+	IP          string // IP of the endpoint, empty for the NodePorts and the Load Balancers with hostname.
+	Hostname    string // Hostname of the Load Balancers that don't publish an IP.
+	Port        int32  // Port of the endpoint.
+	Protocol    string // Protocol of the port.
+}
+
+// ErrLoadBalancerNotReady is returned when the Load Balancer of the service doesn't publish an address for the port.
+var ErrLoadBalancerNotReady = errors.New("the Load Balancer of the service doesn't have an address for the port")
+
+// Address returns the IP or the hostname of the endpoint.
+func (e ExternalEndpoint) Address() string {
+	if e.IP != "" {
+		return e.IP
 	}
+	return e.Hostname
+}
 
-	// Get the list of the LBs
-	loadBalancers, err := GetLoadBalancers(svc)
-	if err != nil {
-		log.Error("Error getting the load balancers: ", err)
-		return nil, err
+var (
+	externalEndpoints     = map[string][]ExternalEndpoint{} // externalEndpoints endpoints by cluster/namespace/name of the service.
+	externalEndpointsLock sync.RWMutex
+	externalEndpointsOnce sync.Once // externalEndpointsOnce makes sure that we only register the callbacks once.
+)
+
+// ServiceEndpoints returns the addresses where the service is reachable from outside the cluster, the ingresses of
+// its Load Balancer (IP or hostname), its NodePorts and its external IPs, for every port of the service.
+func ServiceEndpoints(svc *corev1.Service) []ExternalEndpoint {
+	var endpoints []ExternalEndpoint
+	add := func(endpointType, ip, hostname string, port corev1.ServicePort, portNumber int32) {
+		endpoints = append(endpoints, ExternalEndpoint{
+			ServiceName: svc.Name,
+			Namespace:   svc.Namespace,
+			Type:        endpointType,
+			IP:          ip,
+			Hostname:    hostname,
+			Port:        portNumber,
+			Protocol:    string(port.Protocol),
+		})
 	}
 
-	// Generate the metrics with the LBs
-	err = metrics.WithMetricsLoadBalancer(loadBalancers)
-	if err != nil {
-		log.Error("Failed to update metrics: ", err)
-		return nil, err
+	for _, port := range svc.Spec.Ports {
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" || ingress.Hostname != "" {
+					add(EndpointLoadBalancer, ingress.IP, ingress.Hostname, port, port.Port)
+				}
+			}
+		}
+		if port.NodePort != 0 {
+			add(EndpointNodePort, "", "", port, port.NodePort)
+		}
+		for _, ip := range svc.Spec.ExternalIPs {
+			add(EndpointExternalIP, ip, "", port, port.Port)
+		}
 	}
+	return endpoints
+}
 
-	return loadBalancers, nil
+// endpointsKey returns the key of the external endpoints of the service.
+func endpointsKey(cluster, namespace, name string) string {
+	return cluster + "/" + namespace + "/" + name
 }
 
-// ListServices retrieves the list of services in a namespace
-func ListServices() (*corev1.ServiceList, error) {
-	// Authentication in cluster - using Service Account, Role, RoleBinding
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		log.Error("ERROR: ", err)
-		return nil, err
+// SetServiceEndpoints replaces the external endpoints of the service of the cluster.
+func SetServiceEndpoints(cluster string, svc *corev1.Service) {
+	externalEndpointsOnce.Do(registerEndpointsMetrics)
+
+	endpoints := ServiceEndpoints(svc)
+	for i := range endpoints {
+		endpoints[i].Cluster = cluster
 	}
+	key := endpointsKey(cluster, svc.Namespace, svc.Name)
 
-	// Create the Kubernetes clientSet
-	clientSet, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Error("ERROR: ", err)
-		return nil, err
+	externalEndpointsLock.Lock()
+	defer externalEndpointsLock.Unlock()
+	if len(endpoints) == 0 {
+		delete(externalEndpoints, key)
+		return
 	}
+	externalEndpoints[key] = endpoints
+}
 
-	// Get all services in the namespace
-	services, err := clientSet.CoreV1().Services(GetCurrentNamespace()).List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Error("ERROR: ", err)
-		return nil, err
+// DeleteServiceEndpoints removes the external endpoints of the service of the cluster.
+func DeleteServiceEndpoints(cluster, namespace, name string) {
+	externalEndpointsLock.Lock()
+	defer externalEndpointsLock.Unlock()
+	delete(externalEndpoints, endpointsKey(cluster, namespace, name))
+}
+
+// GetServiceEndpoints returns the external endpoints of the service of the cluster.
+func GetServiceEndpoints(cluster, namespace, name string) []ExternalEndpoint {
+	externalEndpointsLock.RLock()
+	defer externalEndpointsLock.RUnlock()
+	return append([]ExternalEndpoint(nil), externalEndpoints[endpointsKey(cluster, namespace, name)]...)
+}
+
+// GetExternalEndpoints returns the external endpoints of all the services sorted by cluster, namespace and name.
+func GetExternalEndpoints() []ExternalEndpoint {
+	externalEndpointsLock.RLock()
+	defer externalEndpointsLock.RUnlock()
+
+	keys := make([]string, 0, len(externalEndpoints))
+	for key := range externalEndpoints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []ExternalEndpoint
+	for _, key := range keys {
+		result = append(result, externalEndpoints[key]...)
 	}
+	return result
+}
 
-	return services, nil
+// GetLoadBalancerEndpoint returns the address published by the Load Balancer of the service for the port, the port
+// is not checked when the service only exposes one. The services that are not watched yet are read from the API of
+// the cluster of the context.
+func GetLoadBalancerEndpoint(ctx context.Context, namespace, name string, port int32) (ExternalEndpoint, error) {
+	endpoints := GetServiceEndpoints(ClusterFromContext(ctx), namespace, name)
+	if len(endpoints) == 0 {
+		client, err := clientSetFor(ctx)
+		if err != nil {
+			return ExternalEndpoint{}, err
+		}
+		endpoints, err = getServiceEndpoints(ctx, client, namespace, name)
+		if err != nil {
+			log.Error("Error getting the service: [", name, "]: ", err)
+			return ExternalEndpoint{}, err
+		}
+	}
+	return loadBalancerEndpoint(endpoints, port)
 }
 
-// GetLoadBalancers filters the list of services to include only Load Balancers and returns a list of them
-func GetLoadBalancers(svc *corev1.ServiceList) ([]metrics.LoadBalancer, error) {
-	var loadBalancers []metrics.LoadBalancer
+// getServiceEndpoints returns the external endpoints of the service reading it from the API.
+func getServiceEndpoints(ctx context.Context, client kubernetes.Interface, namespace, name string) ([]ExternalEndpoint, error) {
+	svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ServiceEndpoints(svc), nil
+}
 
-	for _, svc := range svc.Items {
-		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
-			for _, ingress := range svc.Status.LoadBalancer.Ingress {
-				log.Info(fmt.Sprintf("Updating metrics for service: [%s] with IP: [%s]", svc.Name, ingress.IP))
-
-				// Create a LoadBalancer struct and append it to the loadBalancers list
-				loadBalancer := metrics.LoadBalancer{
-					ServiceName:      "torch",
-					LoadBalancerName: svc.Name,
-					LoadBalancerIP:   ingress.IP,
-					Namespace:        svc.Namespace,
-					Value:            1, // Set the value of the metric here (e.g., 1)
-				}
-				loadBalancers = append(loadBalancers, loadBalancer)
-			}
+// loadBalancerEndpoint returns the first Load Balancer endpoint of the port, or the first one when all the Load
+// Balancer endpoints use the same port.
+func loadBalancerEndpoint(endpoints []ExternalEndpoint, port int32) (ExternalEndpoint, error) {
+	var loadBalancers []ExternalEndpoint
+	for _, e := range endpoints {
+		if e.Type != EndpointLoadBalancer {
+			continue
 		}
+		if e.Port == port {
+			return e, nil
+		}
+		loadBalancers = append(loadBalancers, e)
 	}
 
 	if len(loadBalancers) == 0 {
-		return nil, errors.New("no Load Balancers found")
+		return ExternalEndpoint{}, ErrLoadBalancerNotReady
 	}
-
-	return loadBalancers, nil
+	for _, e := range loadBalancers[1:] {
+		if e.Port != loadBalancers[0].Port {
+			return ExternalEndpoint{}, ErrLoadBalancerNotReady
+		}
+	}
+	return loadBalancers[0], nil
 }
 
-// WatchServices watches for changes to the services in the specified namespace and updates the metrics accordingly
-func WatchServices(done chan<- error) {
-	defer close(done)
+// GetLoadBalancers returns the Load Balancers of the services, one per ingress.
+func GetLoadBalancers() []metrics.LoadBalancer {
+	var loadBalancers []metrics.LoadBalancer
+	seen := map[string]bool{}
+	for _, e := range GetExternalEndpoints() {
+		// the Load Balancers publish the same ingress for every port of the service.
+		key := endpointsKey(e.Cluster, e.Namespace, e.ServiceName) + "/" + e.Address()
+		if e.Type != EndpointLoadBalancer || seen[key] {
+			continue
+		}
+		seen[key] = true
 
-	// Authentication in cluster - using Service Account, Role, RoleBinding
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		log.Error("Failed to get in-cluster config: ", err)
-		done <- err
-		return
+		loadBalancers = append(loadBalancers, metrics.LoadBalancer{
+			ServiceName:          "torch",
+			LoadBalancerName:     e.ServiceName,
+			LoadBalancerIP:       e.IP,
+			LoadBalancerHostname: e.Hostname,
+			Namespace:            e.Namespace,
+			Value:                1,
+		})
 	}
+	return loadBalancers
+}
 
-	// Create the Kubernetes clientSet
-	clientSet, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Error("Failed to create Kubernetes clientSet: ", err)
-		done <- err
-		return
+// getEndpointsMetrics returns the external endpoints of all the services for the metrics.
+func getEndpointsMetrics() []metrics.ExternalEndpoint {
+	endpoints := GetExternalEndpoints()
+	result := make([]metrics.ExternalEndpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		result = append(result, metrics.ExternalEndpoint{
+			Cluster:     e.Cluster,
+			ServiceName: e.ServiceName,
+			Namespace:   e.Namespace,
+			Type:        e.Type,
+			Address:     e.Address(),
+			Port:        e.Port,
+			Protocol:    e.Protocol,
+		})
 	}
+	return result
+}
 
-	// Create a service watcher
-	watcher, err := clientSet.CoreV1().Services(GetCurrentNamespace()).Watch(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Error("Failed to create service watcher: ", err)
-		done <- err
-		return
+// registerEndpointsMetrics registers the callbacks that observe the Load Balancers and the external endpoints.
+func registerEndpointsMetrics() {
+	if err := metrics.WithMetricsLoadBalancer(GetLoadBalancers); err != nil {
+		log.Error("Failed to register the metric load_balancer: ", err)
 	}
+	if err := metrics.WithMetricsExternalEndpoints(getEndpointsMetrics); err != nil {
+		log.Error("Failed to register the metric external_endpoint: ", err)
+	}
+}
 
-	// Watch for events on the watcher channel
-	for event := range watcher.ResultChan() {
-		if service, ok := event.Object.(*corev1.Service); ok {
-			if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
-				loadBalancers, err := GetLoadBalancers(&corev1.ServiceList{Items: []corev1.Service{*service}})
-				if err != nil {
-					log.Error("Failed to get the load balancers metrics: %v", err)
-					done <- err
-					return
-				}
-
-				if err := metrics.WithMetricsLoadBalancer(loadBalancers); err != nil {
-					log.Error("Failed to update metrics with load balancers: ", err)
-					done <- err
-					return
-				}
+// serviceHandler keeps the external endpoints of the services in sync with the services of the namespace of the
+// cluster.
+func serviceHandler(cluster string) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if svc, ok := obj.(*corev1.Service); ok {
+				SetServiceEndpoints(cluster, svc)
 			}
-		}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// skip the resyncs, the endpoints didn't change.
+			oldSvc, okOld := oldObj.(*corev1.Service)
+			newSvc, okNew := newObj.(*corev1.Service)
+			if !okOld || !okNew || oldSvc.ResourceVersion == newSvc.ResourceVersion {
+				return
+			}
+			SetServiceEndpoints(cluster, newSvc)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if svc, ok := obj.(*corev1.Service); ok {
+				log.Info("Service deleted: [", svc.Name, "], removing its external endpoints")
+				DeleteServiceEndpoints(cluster, svc.Namespace, svc.Name)
+			}
+		},
 	}
 }
@@ -0,0 +1,163 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newService returns a service with the p2p port.
+func newService(name string, serviceType corev1.ServiceType, ingress ...corev1.LoadBalancerIngress) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Type:  serviceType,
+			Ports: []corev1.ServicePort{{Name: "p2p", Port: 2121, NodePort: 32121, Protocol: corev1.ProtocolTCP}},
+		},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: ingress}},
+	}
+}
+
+// TestServiceEndpoints validates the external endpoints of the services.
+func TestServiceEndpoints(t *testing.T) {
+	withExternalIP := newService("da-bridge-3", corev1.ServiceTypeClusterIP)
+	withExternalIP.Spec.Ports[0].NodePort = 0
+	withExternalIP.Spec.ExternalIPs = []string{"203.0.113.3"}
+
+	nodePort := func(name string) ExternalEndpoint {
+		return ExternalEndpoint{ServiceName: name, Namespace: "default", Type: EndpointNodePort, Port: 32121, Protocol: "TCP"}
+	}
+
+	tests := []struct {
+		name string
+		svc  *corev1.Service
+		want []ExternalEndpoint
+	}{
+		{
+			name: "Case 1: Load Balancer with IP",
+			svc:  newService("da-bridge-1", corev1.ServiceTypeLoadBalancer, corev1.LoadBalancerIngress{IP: "203.0.113.1"}),
+			want: []ExternalEndpoint{
+				{ServiceName: "da-bridge-1", Namespace: "default", Type: EndpointLoadBalancer, IP: "203.0.113.1", Port: 2121, Protocol: "TCP"},
+				nodePort("da-bridge-1"),
+			},
+		},
+		{
+			name: "Case 2: Load Balancer with hostname",
+			svc:  newService("da-bridge-2", corev1.ServiceTypeLoadBalancer, corev1.LoadBalancerIngress{Hostname: "lb.example.com"}),
+			want: []ExternalEndpoint{
+				{ServiceName: "da-bridge-2", Namespace: "default", Type: EndpointLoadBalancer, Hostname: "lb.example.com", Port: 2121, Protocol: "TCP"},
+				nodePort("da-bridge-2"),
+			},
+		},
+		{
+			name: "Case 3: Load Balancer without ingress yet",
+			svc:  newService("da-bridge-1", corev1.ServiceTypeLoadBalancer),
+			want: []ExternalEndpoint{nodePort("da-bridge-1")},
+		},
+		{
+			name: "Case 4: External IPs",
+			svc:  withExternalIP,
+			want: []ExternalEndpoint{
+				{ServiceName: "da-bridge-3", Namespace: "default", Type: EndpointExternalIP, IP: "203.0.113.3", Port: 2121, Protocol: "TCP"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServiceEndpoints(tt.svc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServiceEndpoints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetServiceEndpoints validates that the endpoints are kept in sync with the services.
+func TestSetServiceEndpoints(t *testing.T) {
+	svc := newService("da-bridge-1", corev1.ServiceTypeLoadBalancer,
+		corev1.LoadBalancerIngress{IP: "203.0.113.1"}, corev1.LoadBalancerIngress{Hostname: "lb.example.com"})
+	svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{Name: "rpc", Port: 26658, Protocol: corev1.ProtocolTCP})
+	SetServiceEndpoints("", svc)
+	defer DeleteServiceEndpoints("", svc.Namespace, svc.Name)
+
+	// Case 1: one Load Balancer per ingress, not per port.
+	want := []string{"203.0.113.1", "lb.example.com"}
+	var got []string
+	for _, lb := range GetLoadBalancers() {
+		got = append(got, lb.LoadBalancerIP+lb.LoadBalancerHostname)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLoadBalancers() = %v, want %v", got, want)
+	}
+
+	// Case 2: the endpoints are removed when the Load Balancer loses its ingresses.
+	svc.Status.LoadBalancer.Ingress = nil
+	svc.Spec.Ports[0].NodePort = 0
+	SetServiceEndpoints("", svc)
+	if lbs := GetLoadBalancers(); len(lbs) != 0 {
+		t.Errorf("GetLoadBalancers() = %v, want empty", lbs)
+	}
+
+	// Case 3: the endpoints are removed when the service is deleted.
+	SetServiceEndpoints("", newService("da-bridge-2", corev1.ServiceTypeNodePort))
+	DeleteServiceEndpoints("", "default", "da-bridge-2")
+	if endpoints := GetExternalEndpoints(); len(endpoints) != 0 {
+		t.Errorf("GetExternalEndpoints() = %v, want empty", endpoints)
+	}
+}
+
+// TestLoadBalancerEndpoint validates the Load Balancer endpoint chosen for the p2p port.
+func TestLoadBalancerEndpoint(t *testing.T) {
+	multiPort := newService("da-bridge-1", corev1.ServiceTypeLoadBalancer, corev1.LoadBalancerIngress{IP: "203.0.113.1"})
+	multiPort.Spec.Ports = append([]corev1.ServicePort{{Name: "rpc", Port: 26658, Protocol: corev1.ProtocolTCP}},
+		multiPort.Spec.Ports...)
+
+	otherPort := newService("da-bridge-2", corev1.ServiceTypeLoadBalancer, corev1.LoadBalancerIngress{Hostname: "lb.example.com"})
+	otherPort.Spec.Ports[0].Port = 30000
+
+	client := fake.NewSimpleClientset(multiPort, otherPort, newService("da-bridge-3", corev1.ServiceTypeLoadBalancer))
+
+	tests := []struct {
+		name    string
+		service string
+		want    ExternalEndpoint
+		wantErr error
+	}{
+		{
+			name:    "Case 1: endpoint of the p2p port",
+			service: "da-bridge-1",
+			want:    ExternalEndpoint{ServiceName: "da-bridge-1", Namespace: "default", Type: EndpointLoadBalancer, IP: "203.0.113.1", Port: 2121, Protocol: "TCP"},
+		},
+		{
+			name:    "Case 2: service with a single port",
+			service: "da-bridge-2",
+			want:    ExternalEndpoint{ServiceName: "da-bridge-2", Namespace: "default", Type: EndpointLoadBalancer, Hostname: "lb.example.com", Port: 30000, Protocol: "TCP"},
+		},
+		{
+			name:    "Case 3: Load Balancer without ingress yet",
+			service: "da-bridge-3",
+			wantErr: ErrLoadBalancerNotReady,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := getServiceEndpoints(context.Background(), client, "default", tt.service)
+			if err != nil {
+				t.Fatalf("getServiceEndpoints() error = %v", err)
+			}
+			got, err := loadBalancerEndpoint(endpoints, 2121)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("loadBalancerEndpoint() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("loadBalancerEndpoint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
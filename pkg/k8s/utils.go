@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"os"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,3 +18,19 @@ func GetCurrentNamespace() string {
 	}
 	return currentNamespace
 }
+
+// GetEnvInt returns the value of the environment variable as a positive integer.
+// If the variable is not defined or it is not valid, the default value is used.
+func GetEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Error("Invalid ", name, " [", value, "], using the default value: ", defaultValue)
+		return defaultValue
+	}
+	return n
+}
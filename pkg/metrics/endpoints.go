@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ExternalEndpoint represents the information for an address where a service is reachable from outside the cluster.
+type ExternalEndpoint struct {
+	Cluster     string // Cluster of the service, empty for the cluster where Torch runs.
+	ServiceName string // ServiceName Name of the service.
+	Namespace   string // Namespace where the service is deployed.
+	Type        string // Type of the endpoint: LoadBalancer, NodePort or ExternalIP.
+	Address     string // Address IP or hostname of the endpoint, empty for the NodePorts.
+	Port        int32  // Port of the endpoint.
+	Protocol    string // Protocol of the port.
+}
+
+// WithMetricsExternalEndpoints creates a callback function to observe the external endpoints of the services,
+// endpoints is called every time the metrics are collected.
+func WithMetricsExternalEndpoints(endpoints func() []ExternalEndpoint) error {
+	log.Info("registering metric: external_endpoint")
+	// Create an Int64ObservableGauge named "external_endpoint" with a description for the metric.
+	externalEndpointGauge, err := meter.Int64ObservableGauge(
+		"external_endpoint",
+		metric.WithDescription("Torch - External endpoints of the services"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	// Define the callback function that will be called periodically to observe metrics.
+	callback := func(ctx context.Context, observer metric.Observer) error {
+		for _, e := range endpoints() {
+			labels := metric.WithAttributes(
+				attribute.String("cluster", e.Cluster),
+				attribute.String("service_name", e.ServiceName),
+				attribute.String("namespace", e.Namespace),
+				attribute.String("type", e.Type),
+				attribute.String("address", e.Address),
+				attribute.Int("port", int(e.Port)),
+				attribute.String("protocol", e.Protocol),
+			)
+			observer.ObserveInt64(externalEndpointGauge, 1, labels)
+		}
+		return nil
+	}
+
+	// Register the callback with the meter and the Int64ObservableGauge.
+	_, err = meter.RegisterCallback(callback, externalEndpointGauge)
+	return err
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ExternalMultiAddr represents the multi address used to reach a node from outside the cluster.
+type ExternalMultiAddr struct {
+	NodeName    string // NodeName Name of the node.
+	Namespace   string // Namespace where the node is deployed.
+	ServiceName string // ServiceName Name of the Load Balancer service of the node.
+	MultiAddr   string // MultiAddr external Multi Address of the node.
+}
+
+var (
+	externalMultiAddrs     = map[string]ExternalMultiAddr{} // externalMultiAddrs external Multi Addresses by node name.
+	externalMultiAddrsLock sync.RWMutex
+	externalMultiAddrsOnce sync.Once // externalMultiAddrsOnce makes sure that we only register the callback once.
+)
+
+// RegisterExternalMultiAddr stores the external Multi Address of the node, replacing the previous one.
+// The callback that observes the metrics is registered the first time.
+func RegisterExternalMultiAddr(m ExternalMultiAddr) {
+	externalMultiAddrsOnce.Do(func() {
+		if err := withMetricsExternalMultiAddr(); err != nil {
+			log.Error("Error registering the metric external_multiaddr: ", err)
+		}
+	})
+
+	externalMultiAddrsLock.Lock()
+	externalMultiAddrs[m.NodeName] = m
+	externalMultiAddrsLock.Unlock()
+}
+
+// UnregisterExternalMultiAddr removes the external Multi Address of the node.
+func UnregisterExternalMultiAddr(nodeName string) {
+	externalMultiAddrsLock.Lock()
+	defer externalMultiAddrsLock.Unlock()
+	delete(externalMultiAddrs, nodeName)
+}
+
+// GetExternalMultiAddrs returns the external Multi Addresses sorted by node name.
+func GetExternalMultiAddrs() []ExternalMultiAddr {
+	externalMultiAddrsLock.RLock()
+	defer externalMultiAddrsLock.RUnlock()
+
+	result := make([]ExternalMultiAddr, 0, len(externalMultiAddrs))
+	for _, m := range externalMultiAddrs {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NodeName < result[j].NodeName
+	})
+	return result
+}
+
+// withMetricsExternalMultiAddr creates a callback function to observe the external Multi Addresses of the nodes.
+func withMetricsExternalMultiAddr() error {
+	log.Info("registering metric: external_multiaddr")
+	// Create an Int64ObservableGauge named "external_multiaddr" with a description for the metric.
+	externalMultiAddrGauge, err := meter.Int64ObservableGauge(
+		"external_multiaddr",
+		metric.WithDescription("Torch - External MultiAddresses of the nodes"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	// Define the callback function that will be called periodically to observe metrics.
+	callback := func(ctx context.Context, observer metric.Observer) error {
+		for _, m := range GetExternalMultiAddrs() {
+			labels := metric.WithAttributes(
+				attribute.String("node_name", m.NodeName),
+				attribute.String("namespace", m.Namespace),
+				attribute.String("service_name", m.ServiceName),
+				attribute.String("multiaddress", m.MultiAddr),
+			)
+			observer.ObserveInt64(externalMultiAddrGauge, 1, labels)
+		}
+		return nil
+	}
+
+	// Register the callback with the meter and the Int64ObservableGauge.
+	_, err = meter.RegisterCallback(callback, externalMultiAddrGauge)
+	return err
+}
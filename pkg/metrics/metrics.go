@@ -22,9 +22,10 @@ type MultiAddrs struct {
 	Value       float64 // Value to be observed for the Multi Addresses.
 }
 
-// WithMetricsMultiAddress creates a callback function to observe metrics for multiple Multi Addresses.
-func WithMetricsMultiAddress(multiAddrs []MultiAddrs) error {
-	log.Info("registering metric: ", multiAddrs)
+// WithMetricsMultiAddress creates a callback function to observe metrics for multiple Multi Addresses,
+// multiAddrs is called every time the metrics are collected.
+func WithMetricsMultiAddress(multiAddrs func() []MultiAddrs) error {
+	log.Info("registering metric: multiaddr")
 	// Create a Float64ObservableGauge named "Multi Addresses" with a description for the metric.
 	multiAddressesGauge, err := meter.Float64ObservableGauge(
 		"multiaddr",
@@ -37,7 +38,7 @@ func WithMetricsMultiAddress(multiAddrs []MultiAddrs) error {
 
 	// Define the callback function that will be called periodically to observe metrics.
 	callback := func(ctx context.Context, observer metric.Observer) error {
-		for _, ma := range multiAddrs {
+		for _, ma := range multiAddrs() {
 			// Create labels with attributes for each Multi Addresses.
 			labels := metric.WithAttributes(
 				attribute.String("service_name", ma.ServiceName),
@@ -124,15 +125,18 @@ func calculateDaysDifference(inputTimeString string) (int, error) {
 
 // LoadBalancer represents the information for a load balancer.
 type LoadBalancer struct {
-	ServiceName      string  // ServiceName Name of the service associated with the load balancer.
-	LoadBalancerName string  // LoadBalancerName Name of the load balancer.
-	LoadBalancerIP   string  // LoadBalancerIP IP address of the load balancer.
-	Namespace        string  // Namespace where the service is deployed.
-	Value            float64 // Value to be observed for the load balancer.
+	ServiceName          string  // ServiceName Name of the service associated with the load balancer.
+	LoadBalancerName     string  // LoadBalancerName Name of the load balancer.
+	LoadBalancerIP       string  // LoadBalancerIP IP address of the load balancer.
+	LoadBalancerHostname string  // LoadBalancerHostname hostname of the load balancer, used by the clouds without IPs.
+	Namespace            string  // Namespace where the service is deployed.
+	Value                float64 // Value to be observed for the load balancer.
 }
 
-// WithMetricsLoadBalancer creates a callback function to observe metrics for multiple load balancers.
-func WithMetricsLoadBalancer(loadBalancers []LoadBalancer) error {
+// WithMetricsLoadBalancer creates a callback function to observe metrics for multiple load balancers,
+// loadBalancers is called every time the metrics are collected.
+func WithMetricsLoadBalancer(loadBalancers func() []LoadBalancer) error {
+	log.Info("registering metric: load_balancer")
 	// Create a Float64ObservableGauge named "load_balancer" with a description for the metric.
 	loadBalancersGauge, err := meter.Float64ObservableGauge(
 		"load_balancer",
@@ -145,12 +149,13 @@ func WithMetricsLoadBalancer(loadBalancers []LoadBalancer) error {
 
 	// Define the callback function that will be called periodically to observe metrics.
 	callback := func(ctx context.Context, observer metric.Observer) error {
-		for _, lb := range loadBalancers {
+		for _, lb := range loadBalancers() {
 			// Create labels with attributes for each load balancer.
 			labels := metric.WithAttributes(
 				attribute.String("service_name", lb.ServiceName),
 				attribute.String("load_balancer_name", lb.LoadBalancerName),
 				attribute.String("load_balancer_ip", lb.LoadBalancerIP),
+				attribute.String("load_balancer_hostname", lb.LoadBalancerHostname),
 				attribute.String("namespace", lb.Namespace),
 			)
 			// Observe the float64 value for the current load balancer with the associated labels.
@@ -182,7 +187,7 @@ func RegisterConsensusNodeMetric(nodeID, nodeName, namespace string) error {
 		metric.WithDescription("Metric for Consensus Node IDs"),
 	)
 	if err != nil {
-		log.Fatalf("Error creating metric: ", err)
+		log.Fatal("Error creating metric: ", err)
 		return err
 	}
 
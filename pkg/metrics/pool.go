@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WithMetricsPool creates a callback function to observe the utilization of a pool of workers.
+// size is the max number of workers and inUse returns the number of workers busy when the metrics are collected.
+func WithMetricsPool(pool string, size int64, inUse func() int64) error {
+	log.Info("registering metric for the pool: ", pool)
+	// Create the Int64ObservableGauges with the size and the workers busy of the pool.
+	poolSizeGauge, err := meter.Int64ObservableGauge(
+		"worker_pool_size",
+		metric.WithDescription("Torch - Max number of workers in the pool"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	poolInUseGauge, err := meter.Int64ObservableGauge(
+		"worker_pool_in_use",
+		metric.WithDescription("Torch - Number of workers busy in the pool"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	// Define the callback function that will be called periodically to observe metrics.
+	callback := func(ctx context.Context, observer metric.Observer) error {
+		labels := metric.WithAttributes(
+			attribute.String("pool", pool),
+		)
+		observer.ObserveInt64(poolSizeGauge, size, labels)
+		observer.ObserveInt64(poolInUseGauge, inUse(), labels)
+
+		return nil
+	}
+
+	// Register the callback with the meter and the Int64ObservableGauges.
+	_, err = meter.RegisterCallback(callback, poolSizeGauge, poolInUseGauge)
+	return err
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ReconcileStatus represents the result of the last reconciliation of a node.
+type ReconcileStatus struct {
+	NodeName  string // NodeName Name of the node.
+	Namespace string // Namespace of the node.
+	State     string // State result of the reconciliation.
+	Timestamp int64  // Timestamp unix time of the reconciliation.
+}
+
+var (
+	reconcileStatus     = map[string]ReconcileStatus{} // reconcileStatus last reconciliation by node name.
+	reconcileStatusLock sync.RWMutex
+	reconcileStatusOnce sync.Once // reconcileStatusOnce makes sure that we only register the callback once.
+)
+
+// RecordReconcileStatus stores the result of the last reconciliation of the node.
+func RecordReconcileStatus(status ReconcileStatus) {
+	reconcileStatusOnce.Do(func() {
+		if err := withMetricsReconcile(); err != nil {
+			log.Error("Error registering the metric reconcile_status: ", err)
+		}
+	})
+
+	reconcileStatusLock.Lock()
+	reconcileStatus[status.NodeName] = status
+	reconcileStatusLock.Unlock()
+}
+
+// withMetricsReconcile creates a callback function to observe the result and the time of the last reconciliation
+// of every node.
+func withMetricsReconcile() error {
+	// Create a Float64ObservableGauge named "reconcile_status" with a description for the metric.
+	reconcileStatusGauge, err := meter.Float64ObservableGauge(
+		"reconcile_status",
+		metric.WithDescription("Torch - Result of the last reconciliation of the node"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	reconcileTimestampGauge, err := meter.Int64ObservableGauge(
+		"reconcile_last_timestamp",
+		metric.WithDescription("Torch - Unix time of the last reconciliation of the node"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	// Define the callback function that will be called periodically to observe metrics.
+	callback := func(ctx context.Context, observer metric.Observer) error {
+		reconcileStatusLock.RLock()
+		defer reconcileStatusLock.RUnlock()
+
+		for _, status := range reconcileStatus {
+			labels := metric.WithAttributes(
+				attribute.String("node_name", status.NodeName),
+				attribute.String("namespace", status.Namespace),
+			)
+			observer.ObserveFloat64(reconcileStatusGauge, 1, metric.WithAttributes(
+				attribute.String("node_name", status.NodeName),
+				attribute.String("namespace", status.Namespace),
+				attribute.String("state", status.State),
+			))
+			observer.ObserveInt64(reconcileTimestampGauge, status.Timestamp, labels)
+		}
+
+		return nil
+	}
+
+	// Register the callback with the meter and the ObservableGauges.
+	_, err = meter.RegisterCallback(callback, reconcileStatusGauge, reconcileTimestampGauge)
+	return err
+}
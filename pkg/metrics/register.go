@@ -1,17 +1,26 @@
 package metrics
 
 import (
+	"sort"
+	"sync"
+
 	log "github.com/sirupsen/logrus"
 )
 
-// Declare a slice to hold multiple Multi Addresses metrics.
-var multiAddresses []MultiAddrs
+var (
+	multiAddresses     = map[string]MultiAddrs{} // multiAddresses Multi Addresses metrics by node name.
+	multiAddressesLock sync.RWMutex
+	multiAddressesOnce sync.Once // multiAddressesOnce makes sure that we only register the callback once.
+)
 
-// MultiAddrExists checks if a given MultiAddr already exists in the multiAddresses slice.
+// MultiAddrExists checks if a given MultiAddr already exists in the multiAddresses metrics.
 // It returns true if the MultiAddr already exists, and false otherwise.
 func MultiAddrExists(multiAddr string) bool {
+	multiAddressesLock.RLock()
+	defer multiAddressesLock.RUnlock()
+
 	for _, addr := range multiAddresses {
-		// Compare each MultiAddr in the slice with the provided multiAddr.
+		// Compare each MultiAddr with the provided multiAddr.
 		if addr.MultiAddr == multiAddr {
 			return true
 		}
@@ -19,23 +28,50 @@ func MultiAddrExists(multiAddr string) bool {
 	return false
 }
 
-// RegisterMetric adds a new Multi Addresses metric to the multiAddresses slice.
-// Before adding, it checks if the MultiAddr already exists in the slice using MultiAddrExists function.
+// RegisterMetric adds a new Multi Addresses metric, replacing the previous one of the node.
+// Before adding, it checks if the MultiAddr already exists using MultiAddrExists function.
 // If the MultiAddr already exists, it logs a message and skips the addition.
-// Otherwise, it appends the new Multi Addresses to the slice and registers the updated metrics.
+// The callback that observes the metrics is registered the first time.
 func RegisterMetric(m MultiAddrs) {
-	// Check if the MultiAddr already exists in the array
+	multiAddressesOnce.Do(func() {
+		if err := WithMetricsMultiAddress(GetMultiAddrs); err != nil {
+			log.Printf("Failed to register metrics: %v", err)
+		}
+	})
+
+	// Check if the MultiAddr already exists
 	if MultiAddrExists(m.MultiAddr) {
 		log.Info("MultiAddr already exists in the metrics array: ", m.NodeName, " ", m.MultiAddr)
 		return
 	}
 
-	// Append the new MultiAddr to the array
-	multiAddresses = append(multiAddresses, m)
+	multiAddressesLock.Lock()
+	multiAddresses[m.NodeName] = m
+	multiAddressesLock.Unlock()
+}
+
+// UnregisterMetric removes the Multi Addresses metric of the node.
+func UnregisterMetric(nodeName string) {
+	multiAddressesLock.Lock()
+	defer multiAddressesLock.Unlock()
+
+	if _, ok := multiAddresses[nodeName]; ok {
+		log.Info("Removing the MultiAddr metric of the node: ", nodeName)
+		delete(multiAddresses, nodeName)
+	}
+}
+
+// GetMultiAddrs returns the Multi Addresses metrics sorted by node name.
+func GetMultiAddrs() []MultiAddrs {
+	multiAddressesLock.RLock()
+	defer multiAddressesLock.RUnlock()
 
-	// Register the metric
-	err := WithMetricsMultiAddress(multiAddresses)
-	if err != nil {
-		log.Printf("Failed to update metrics: %v", err)
+	result := make([]MultiAddrs, 0, len(multiAddresses))
+	for _, m := range multiAddresses {
+		result = append(result, m)
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NodeName < result[j].NodeName
+	})
+	return result
 }
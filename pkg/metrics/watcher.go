@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// watcherKey identifies the watcher of a resource in a cluster.
+type watcherKey struct {
+	cluster  string // cluster of the watcher, empty for the cluster where Torch runs.
+	resource string // resource watched.
+}
+
+var (
+	watcherEventsOnce sync.Once                // watcherEventsOnce makes sure that we only register the callback once.
+	watcherEvents     = map[watcherKey]int64{} // watcherEvents unix time of the last event received by every watcher.
+	watcherEventsLock sync.RWMutex
+)
+
+// RecordWatcherEvent stores the time of the last event received by the watcher of the resource in the cluster.
+func RecordWatcherEvent(cluster, resource string) {
+	watcherEventsOnce.Do(func() {
+		if err := withMetricsWatcherEvents(); err != nil {
+			log.Error("Error registering the metric watcher_last_event_timestamp: ", err)
+		}
+	})
+
+	watcherEventsLock.Lock()
+	watcherEvents[watcherKey{cluster: cluster, resource: resource}] = time.Now().Unix()
+	watcherEventsLock.Unlock()
+}
+
+// withMetricsWatcherEvents creates a callback function to observe the time of the last event of every watcher.
+func withMetricsWatcherEvents() error {
+	// Create an Int64ObservableGauge named "watcher_last_event_timestamp" with a description for the metric.
+	lastEventGauge, err := meter.Int64ObservableGauge(
+		"watcher_last_event_timestamp",
+		metric.WithDescription("Torch - Unix time of the last event received by the watcher"),
+	)
+	if err != nil {
+		log.Error("Error creating metric: ", err)
+		return err
+	}
+
+	// Define the callback function that will be called periodically to observe metrics.
+	callback := func(ctx context.Context, observer metric.Observer) error {
+		watcherEventsLock.RLock()
+		defer watcherEventsLock.RUnlock()
+
+		for key, timestamp := range watcherEvents {
+			labels := metric.WithAttributes(
+				attribute.String("cluster", key.cluster),
+				attribute.String("resource", key.resource),
+			)
+			observer.ObserveInt64(lastEventGauge, timestamp, labels)
+		}
+
+		return nil
+	}
+
+	// Register the callback with the meter and the Int64ObservableGauge.
+	_, err = meter.RegisterCallback(callback, lastEventGauge)
+	return err
+}
@@ -0,0 +1,46 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+// ErrConnectionNotStored is returned when the id of a node that the peer connects to is not stored in the DB yet.
+var ErrConnectionNotStored = errors.New("the id of the connection is not stored yet")
+
+// StoredConnectionString returns the multi addresses of the nodes that the peer connects to, separated by commas,
+// using only the ids stored in the DB. Unlike BuildConnectionString, it doesn't run commands in the nodes, so it can be
+// used while the pod of the peer is being created.
+func StoredConnectionString(ctx context.Context, red *redis.RedisClient, peer config.Peer) (string, error) {
+	conns := make([]string, 0, len(peer.ConnectsTo))
+	for index, nodeName := range peer.ConnectsTo {
+		ma, err := redis.CheckIfNodeExistsInDB(red, ctx, nodeName)
+		if err != nil {
+			return "", err
+		}
+
+		ma, addPrefix := VerifyAndUpdateMultiAddress(peer, index, ma, true)
+		if ma == "" {
+			return "", fmt.Errorf("%w: [%s]", ErrConnectionNotStored, nodeName)
+		}
+
+		if addPrefix {
+			if NodeCluster(peer, nodeName) != peer.Cluster {
+				ma, err = externalConnection(ctx, red, nodeName, ma)
+			} else {
+				ma, err = SetIdPrefix(peer, ma, index, ctx)
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		conns = append(conns, ma)
+	}
+
+	return strings.Join(conns, ","), nil
+}
@@ -0,0 +1,91 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+// ErrNoExternalMultiAddr is returned when a node connects to a node of another cluster that doesn't have an external
+// multi address yet.
+var ErrNoExternalMultiAddr = errors.New("the node runs in another cluster and doesn't have an external multi address")
+
+// configPeers peers of the config by node name, used to find the cluster of the nodes that a peer connects to.
+var configPeers atomic.Value
+
+// SetPeers stores the peers of the config, so the connections know the cluster where every node runs.
+func SetPeers(cfg config.MutualPeersConfig) {
+	peers := map[string]config.Peer{}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			peers[peer.NodeName] = peer
+		}
+	}
+	configPeers.Store(peers)
+}
+
+// configPeer returns the peer of the config of the node.
+func configPeer(nodeName string) (config.Peer, bool) {
+	peers, _ := configPeers.Load().(map[string]config.Peer)
+	peer, ok := peers[nodeName]
+	return peer, ok
+}
+
+// NodeCluster returns the cluster where the node that the peer connects to runs, the nodes that are not in the config
+// run in the cluster of the peer.
+func NodeCluster(peer config.Peer, nodeName string) string {
+	if nodeName == peer.NodeName {
+		return peer.Cluster
+	}
+	if p, ok := configPeer(nodeName); ok {
+		return p.Cluster
+	}
+	return peer.Cluster
+}
+
+// clusterNamespace returns the namespace of the node in its cluster, the namespace of the peer when it is the node
+// itself, otherwise the namespace watched in the cluster.
+func clusterNamespace(peer config.Peer, nodeName string) string {
+	if nodeName == peer.NodeName && peer.Namespace != "" {
+		return peer.Namespace
+	}
+	return k8s.ClusterNamespace(NodeCluster(peer, nodeName))
+}
+
+// recordPodEvent attaches the event to the pod when it runs in the cluster where Torch runs, the events of the pods of
+// the remote clusters are only logged.
+func recordPodEvent(cluster, namespace, podName, eventType, reason, messageFmt string, args ...interface{}) {
+	if cluster != "" {
+		log.Debug("Skipping the event [", reason, "] of the pod [", podName, "] in the cluster [", cluster, "]")
+		return
+	}
+	k8s.RecordPodEvent(namespace, podName, eventType, reason, messageFmt, args...)
+}
+
+// externalConnection returns the external multi address of the node of another cluster, generating it from its
+// Load Balancer if it is not stored yet.
+func externalConnection(ctx context.Context, red *redis.RedisClient, nodeName, id string) (string, error) {
+	ma, err := GetExternalMultiAddr(ctx, red, nodeName)
+	if err != nil || ma != "" {
+		return ma, err
+	}
+
+	target, ok := configPeer(nodeName)
+	if ok {
+		ma, err = UpdateExternalMultiAddr(ctx, red, SetNodeDefault(target), id)
+	}
+	if err != nil {
+		return "", err
+	}
+	if ma == "" {
+		log.Error("The node [", nodeName, "] runs in another cluster and doesn't have an external service")
+		return "", ErrNoExternalMultiAddr
+	}
+	return ma, nil
+}
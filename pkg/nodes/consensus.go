@@ -1,24 +1,28 @@
 package nodes
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
 	"github.com/jrmanes/torch/pkg/k8s"
 )
 
 var (
-	consContainerSetupName = "consensus-setup"         // consContainerSetupName initContainer that we use to configure the nodes.
-	consContainerName      = "consensus"               // consContainerName container name which the pod runs.
-	namespace              = k8s.GetCurrentNamespace() // namespace of the node.
+	consContainerSetupName = "consensus-setup" // consContainerSetupName initContainer that we use to configure the nodes.
+	consContainerName      = "consensus"       // consContainerName container name which the pod runs.
 )
 
+const consensusRPCPort = "26657" // consensusRPCPort port of the API of the consensus nodes.
+
 // SetConsNodeDefault sets all the default values in case they are empty
 func SetConsNodeDefault(peer config.Peer) config.Peer {
 	if peer.ContainerSetupName == "" {
@@ -28,7 +32,7 @@ func SetConsNodeDefault(peer config.Peer) config.Peer {
 		peer.ContainerName = consContainerName
 	}
 	if peer.Namespace == "" {
-		peer.Namespace = namespace
+		peer.Namespace = k8s.ClusterNamespace(peer.Cluster)
 	}
 	return peer
 }
@@ -36,7 +40,7 @@ func SetConsNodeDefault(peer config.Peer) config.Peer {
 // GenesisHash connects to the specified consensus node, makes a request to the API,
 // and retrieves information about the genesis block including its hash and time.
 func GenesisHash(consensusNode string) (string, string, error) {
-	url := fmt.Sprintf("http://%s:26657/block?height=1", consensusNode)
+	url := fmt.Sprintf("http://%s/block?height=1", net.JoinHostPort(consensusNode, consensusRPCPort))
 	jsonResponse, err := makeAPIRequest(url)
 	if err != nil {
 		return "", "", err
@@ -60,7 +64,7 @@ func GenesisHash(consensusNode string) (string, string, error) {
 // ConsensusNodesIDs connects to the specified consensus node, makes a request to the API,
 // and retrieves the node ID from the status response.
 func ConsensusNodesIDs(consensusNode string) (string, error) {
-	url := fmt.Sprintf("http://%s:26657/status?", consensusNode)
+	url := fmt.Sprintf("http://%s/status?", net.JoinHostPort(consensusNode, consensusRPCPort))
 	jsonResponse, err := makeAPIRequest(url)
 	if err != nil {
 		return "", err
@@ -77,6 +81,58 @@ func ConsensusNodesIDs(consensusNode string) (string, error) {
 	return nodeID, nil
 }
 
+// consensusHost returns the host used to reach the API of the consensus node: its service, or the IP of its pod if
+// the service is not defined, the name of the pod doesn't resolve without the headless service.
+func consensusHost(ctx context.Context, peer config.Peer) (string, error) {
+	if peer.ServiceName != "" {
+		return peer.ServiceName, nil
+	}
+
+	namespace := peer.Namespace
+	if namespace == "" {
+		namespace = k8s.ClusterNamespace(peer.Cluster)
+	}
+	ips, err := k8s.GetPodIPs(k8s.WithCluster(ctx, peer.Cluster), namespace, peer.NodeName, peer.IPSource)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("the pod [%s] has no IPs", peer.NodeName)
+	}
+	return ips[0], nil
+}
+
+// GetConsensusNodeIdAndSaveIt gets the node ID of a consensus node from its API and stores it, the API is reached
+// using the service of the node, or the IP of its pod if the service is not defined.
+func GetConsensusNodeIdAndSaveIt(peer config.Peer, red *redis.RedisClient, ctx context.Context) (string, error) {
+	return dedupe("id/"+peer.NodeName, func() (string, error) {
+		nodeID := ""
+		err := WithNodeLock(ctx, red, peer.NodeName, func(lock *redis.Lock) error {
+			// the id is restored from the annotations of the pod when it is not in the DB.
+			if nodeID = IdentityFromPod(ctx, peer.Namespace, peer.NodeName, "consensus"); nodeID != "" {
+				return redis.SetNodeIdLocked(lock, peer.NodeName, red, ctx, nodeID)
+			}
+
+			host, err := consensusHost(ctx, peer)
+			if err != nil {
+				log.Error("Error getting the host of the consensus node: [", peer.NodeName, "]: ", err)
+				return err
+			}
+			nodeID, err = ConsensusNodesIDs(host)
+			if err != nil {
+				return err
+			}
+
+			if err := redis.SetNodeIdLocked(lock, peer.NodeName, red, ctx, nodeID); err != nil {
+				return err
+			}
+			AnnotateIdentity(ctx, peer.Namespace, peer.NodeName, nodeID, "")
+			return nil
+		})
+		return nodeID, err
+	})
+}
+
 // makeAPIRequest handles the common task of making an HTTP request to a given URL
 // and parsing the JSON response. It returns a map representing the JSON response or an error.
 func makeAPIRequest(url string) (map[string]interface{}, error) {
@@ -1,10 +1,16 @@
 package nodes
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/k8s/fake"
 )
 
 func TestSetConsNodeDefault(t *testing.T) {
@@ -25,6 +31,7 @@ func TestSetConsNodeDefault(t *testing.T) {
 				},
 			},
 			want: config.Peer{
+				Namespace:          k8s.GetCurrentNamespace(),
 				NodeName:           "consensus-full-1",
 				NodeType:           "consensus",
 				ContainerName:      "consensus",
@@ -45,6 +52,7 @@ func TestSetConsNodeDefault(t *testing.T) {
 				},
 			},
 			want: config.Peer{
+				Namespace:          k8s.GetCurrentNamespace(),
 				NodeName:           "consensus-full-1",
 				NodeType:           "consensus",
 				ContainerName:      "consensus",
@@ -63,3 +71,43 @@ func TestSetConsNodeDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestConsensusHost(t *testing.T) {
+	namespace := k8s.GetCurrentNamespace()
+	pod := fake.RunningPod("consensus-full-1-0", namespace, consContainerName)
+	pod.Status.PodIPs = []corev1.PodIP{{IP: "fd00::1"}}
+	k8s.SetClientSet(k8sfake.NewSimpleClientset(pod))
+	t.Cleanup(func() { k8s.SetClientSet(nil) })
+
+	tests := []struct {
+		name    string
+		peer    config.Peer
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Case 1: the service of the node",
+			peer: config.Peer{NodeName: "consensus-full-1-0", ServiceName: "consensus-full-1"},
+			want: "consensus-full-1",
+		},
+		{
+			name: "Case 2: the IP of the pod when the service is not defined",
+			peer: config.Peer{NodeName: "consensus-full-1-0"},
+			want: "fd00::1",
+		},
+		{
+			name:    "Case 3: the pod doesn't exist",
+			peer:    config.Peer{NodeName: "consensus-full-2-0"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := consensusHost(context.Background(), tt.peer)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("consensusHost() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
@@ -19,13 +22,13 @@ const (
 	errRemoteCommand = "Error executing remote command: "
 	timeoutDuration  = 60 * time.Second // timeoutDuration we specify the max time to run the func.
 	nodeIdMaxLength  = 52               // nodeIdMaxLength Specify the max length for the nodes ids.
+	p2pPort          = 2121             // p2pPort port used by the nodes to connect to each other.
 )
 
 var (
 	daContainerSetupName = "da-setup"                     // daContainerSetupName initContainer that we use to configure the nodes.
 	daContainerName      = "da"                           // daContainerName container name which the pod runs.
 	fPathDA              = "/tmp/celestia-config/TP-ADDR" // fPathDA path to the file where Torch will write.
-	ns                   = k8s.GetCurrentNamespace()      // ns namespace of the node.
 )
 
 // SetDaNodeDefault sets all the default values in case they are empty
@@ -37,22 +40,57 @@ func SetDaNodeDefault(peer config.Peer) config.Peer {
 		peer.ContainerName = daContainerName
 	}
 	if peer.Namespace == "" {
-		peer.Namespace = ns
+		peer.Namespace = k8s.ClusterNamespace(peer.Cluster)
 	}
 	return peer
 }
 
-// SetupDANodeWithConnections configure a DA node with connections
+// SetupDANodeWithConnections configure a DA node with connections, concurrent requests for the same node share
+// the same execution.
 func SetupDANodeWithConnections(peer config.Peer) error {
+	_, err := dedupe("setup/"+peer.NodeName, func() (string, error) {
+		return "", setupDANodeWithConnections(peer)
+	})
+	return err
+}
+
+// setupDANodeWithConnections configure a DA node with connections, the nodes without connections don't need any file.
+func setupDANodeWithConnections(peer config.Peer) error {
+	if len(peer.ConnectsTo) == 0 {
+		log.Info("Node [", peer.NodeName, "] doesn't connect to any node, nothing to configure")
+		return nil
+	}
+
 	red := redis.InitRedisConfig()
 	// Create a new context with a timeout
 	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
-	connString := ""
-	addPrefix := true
 
 	// Make sure to call the cancel function to release resources when you're done
 	defer cancel()
 
+	connString, err := BuildConnectionString(peer, red, ctx)
+	if err != nil {
+		return err
+	}
+
+	// execute the command against the node, using the setup container as the node is waiting for the file.
+	if err := WriteNodeFile(ctx, red, peer, peer.ContainerSetupName, fPathDA, connString); err != nil {
+		return err
+	}
+	log.Info("MultiAddr for node ", peer.NodeName, " is: [", connString, "]")
+
+	log.Info("Adding node to the queue: [", peer.NodeName, "]")
+	AddToQueue(peer)
+
+	return nil
+}
+
+// BuildConnectionString returns the multi addresses of the nodes that the peer connects to, separated by commas,
+// the ids of the nodes that are not in the DB are generated.
+func BuildConnectionString(peer config.Peer, red *redis.RedisClient, ctx context.Context) (string, error) {
+	connString := ""
+	addPrefix := true
+
 	// read the connection list
 	for index, nodeName := range peer.ConnectsTo {
 		log.Info(peer.NodeName, " , connection: [", index, "] to node: [", nodeName, "]")
@@ -61,11 +99,12 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 		ma, err := redis.CheckIfNodeExistsInDB(red, ctx, nodeName)
 		if err != nil {
 			log.Error("Error CheckIfNodeExistsInDB for full-node: [", peer.NodeName, "]", err)
-			return err
+			return "", err
 		}
 
 		// check if the MA is already in the config
 		ma, addPrefix = VerifyAndUpdateMultiAddress(peer, index, ma, addPrefix)
+		cluster := NodeCluster(peer, nodeName)
 
 		// if the node is not in the db, then we generate it
 		if ma == "" {
@@ -73,19 +112,27 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 			ma, err = GenerateNodeIdAndSaveIt(peer, peer.ConnectsTo[index], red, ctx)
 			if err != nil {
 				log.Error("Error GenerateNodeIdAndSaveIt for full-node: [", peer.NodeName, "]", err)
-				return err
+				return "", err
 			}
 		}
 
-		// if we have the address already, lets continue the process, otherwise, means we couldn't get the node id
-		if ma != "" && addPrefix {
+		// the nodes of other clusters are reached using the Load Balancer of their external service.
+		if ma != "" && addPrefix && cluster != peer.Cluster {
+			ma, err = externalConnection(ctx, red, nodeName, ma)
+			if err != nil {
+				log.Error("Error getting the external multi address of the node: [", nodeName, "]: ", err)
+				return "", err
+			}
+			log.Info("Peer connection to the cluster [", cluster, "]: ", ma)
+		} else if ma != "" && addPrefix {
 			// adding the node prefix
-			ma, err = SetIdPrefix(peer, ma, index)
+			ma, err = SetIdPrefix(peer, ma, index, ctx)
 			if err != nil {
 				log.Error("Error SetIdPrefix for full-node: [", peer.NodeName, "]", err)
-				return err
+				return "", err
 			}
 			log.Info("Peer connection prefix: ", ma)
+			AnnotateIdentity(k8s.WithCluster(ctx, cluster), k8s.ClusterNamespace(cluster), nodeName, "", ma)
 		}
 
 		// check the connection index and concatenate it in case we have more than one node
@@ -95,11 +142,12 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 			connString = ma
 		}
 
-		// validate the MA, must start with /ip4/ || /dns/
-		if !strings.HasPrefix(ma, "/ip4/") && !strings.HasPrefix(ma, "/dns/") {
-			errorMessage := fmt.Sprintf("Error generating the MultiAddress, must begin with /ip4/ || /dns/: [%s]", ma)
+		// validate the MA, must start with /ip4/ || /ip6/ || /dns/ || /dns4/
+		if !strings.HasPrefix(ma, "/ip4/") && !strings.HasPrefix(ma, "/ip6/") &&
+			!strings.HasPrefix(ma, "/dns/") && !strings.HasPrefix(ma, "/dns4/") {
+			errorMessage := fmt.Sprintf("Error generating the MultiAddress, must begin with /ip4/ || /ip6/ || /dns/ || /dns4/: [%s]", ma)
 			log.Error(errorMessage)
-			return errors.New(errorMessage)
+			return "", errors.New(errorMessage)
 		}
 
 		log.Info("Registering metric for node: [", nodeName, "]")
@@ -113,33 +161,18 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 			Value:       1,
 		}
 		metrics.RegisterMetric(m)
-
-		// get the command to write in a file and execute the command against the node
-		command := k8s.WriteToFile(connString, fPathDA)
-		output, err := k8s.RunRemoteCommand(
-			peer.NodeName,
-			peer.ContainerSetupName,
-			k8s.GetCurrentNamespace(),
-			command)
-		if err != nil {
-			log.Error(errRemoteCommand, err)
-			return err
-		}
-
-		log.Info("MultiAddr for node ", peer.NodeName, " is: [", output, "]")
-
-		log.Info("Adding node to the queue: [", peer.NodeName, "]")
-		go AddToQueue(peer)
 	}
 
-	return nil
+	return connString, nil
 }
 
 // VerifyAndUpdateMultiAddress checks if the configuration contains a Multi Address at the specified index
 // and updates it if found. It returns the verified Multi Address and a boolean indicating if an update was performed.
 func VerifyAndUpdateMultiAddress(peer config.Peer, index int, currentAddr string, addPrefix bool) (string, bool) {
 	// verify that we have the multi addr already specify in the config
-	if strings.Contains(peer.ConnectsTo[index], "dns") || strings.Contains(peer.ConnectsTo[index], "ip4") {
+	if strings.Contains(peer.ConnectsTo[index], "dns") ||
+		strings.Contains(peer.ConnectsTo[index], "ip4") ||
+		strings.Contains(peer.ConnectsTo[index], "ip6") {
 		// Use the address from the configuration
 		currentAddr = peer.ConnectsTo[index]
 		addPrefix = false
@@ -147,41 +180,108 @@ func VerifyAndUpdateMultiAddress(peer config.Peer, index int, currentAddr string
 	return currentAddr, addPrefix
 }
 
-// SetIdPrefix generates the prefix depending on dns or ip
-func SetIdPrefix(peer config.Peer, c string, i int) (string, error) {
+// SetIdPrefix generates the prefix depending on dns or ip, the nodes without DNS are reached using the IPs of their
+// pods, with one multi address per IP family.
+func SetIdPrefix(peer config.Peer, c string, i int, ctx context.Context) (string, error) {
 	// check if we are using DNS or IP
 	if len(peer.DnsConnections) > 0 {
-		c = "/dns/" + peer.DnsConnections[i] + "/tcp/2121/p2p/" + c
-	} else {
-		comm := k8s.GetNodeIP()
-		output, err := k8s.RunRemoteCommand(
-			peer.ConnectsTo[i],
-			peer.ContainerName,
-			k8s.GetCurrentNamespace(),
-			comm)
-		if err != nil {
-			log.Error(errRemoteCommand, err)
-			return "", err
-		}
-		log.Info("command - ip is: ", output)
-		c = output + c
+		return "/dns/" + peer.DnsConnections[i] + "/tcp/2121/p2p/" + c, nil
+	}
+
+	cluster := NodeCluster(peer, peer.ConnectsTo[i])
+	ips, err := k8s.GetPodIPs(
+		k8s.WithCluster(ctx, cluster),
+		k8s.ClusterNamespace(cluster),
+		peer.ConnectsTo[i],
+		peer.IPSource,
+	)
+	if err != nil {
+		log.Error("Error getting the IPs of the node: [", peer.ConnectsTo[i], "]: ", err)
+		return "", err
+	}
+	log.Info("IPs of the node [", peer.ConnectsTo[i], "]: ", ips)
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, IPMultiAddr(ip, c))
+	}
+	return strings.Join(addrs, ","), nil
+}
+
+// IPMultiAddr returns the multi address of the node id using the IP, /ip4/ or /ip6/ depending on its family.
+func IPMultiAddr(ip, id string) string {
+	return ipMultiAddr(ip, p2pPort, id)
+}
+
+// ipMultiAddr returns the multi address of the node id using the IP and the port.
+func ipMultiAddr(ip string, port int32, id string) string {
+	protocol := "ip6"
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+		protocol = "ip4"
 	}
-	return c, nil
+	return "/" + protocol + "/" + ip + "/tcp/" + strconv.Itoa(int(port)) + "/p2p/" + id
 }
 
-// GenerateNodeIdAndSaveIt generates the node id and store it
+// GenerateNodeIdAndSaveIt generates the node id and store it, concurrent requests for the same node share the
+// same execution and the result.
 func GenerateNodeIdAndSaveIt(
 	pod config.Peer,
 	connNode string,
 	red *redis.RedisClient,
 	ctx context.Context,
 ) (string, error) {
+	return dedupe("id/"+connNode, func() (string, error) {
+		output := ""
+		err := WithNodeLock(ctx, red, connNode, func(lock *redis.Lock) error {
+			var err error
+			output, err = generateNodeIdAndSaveIt(pod, connNode, red, ctx, lock)
+			return err
+		})
+		return output, err
+	})
+}
+
+// generateNodeIdAndSaveIt generates the node id and store it while holding the lock of the node.
+func generateNodeIdAndSaveIt(
+	pod config.Peer,
+	connNode string,
+	red *redis.RedisClient,
+	ctx context.Context,
+	lock *redis.Lock,
+) (string, error) {
+	// another replica might have generated the id while we were waiting for the lock.
+	ma, err := redis.CheckIfNodeExistsInDB(red, ctx, connNode)
+	if err != nil {
+		return "", err
+	}
+	if ma != "" {
+		log.Info("Node ", "["+connNode+"]"+" found in DB after acquiring the lock: [", ma, "]")
+		return ma, nil
+	}
+
+	// the connection nodes are in the namespace watched in their cluster, unless we are generating the id of the pod
+	// itself.
+	cluster := NodeCluster(pod, connNode)
+	namespace := clusterNamespace(pod, connNode)
+	ctx = k8s.WithCluster(ctx, cluster)
+
+	// the id is restored from the annotations of the pod when it is not in the DB, e.g. after losing the data of Redis.
+	if id := IdentityFromPod(ctx, namespace, connNode, "da"); id != "" {
+		log.Info("Node ", "["+connNode+"]"+" found in the annotations of the pod: [", id, "]")
+		if err := redis.SetNodeIdLocked(lock, connNode, red, ctx, id); err != nil {
+			log.Error("Error SetNodeId: ", err)
+			return "", err
+		}
+		return id, nil
+	}
+
 	// Generate the command and run it against the connection node + it's running container
 	command := k8s.CreateTrustedPeerCommand()
 	output, err := k8s.RunRemoteCommand(
+		ctx,
 		connNode,
 		pod.ContainerName,
-		k8s.GetCurrentNamespace(),
+		namespace,
 		command)
 	if err != nil {
 		log.Error(errRemoteCommand, err)
@@ -201,14 +301,17 @@ func GenerateNodeIdAndSaveIt(
 		}
 
 		// save node in redis
-		err = redis.SetNodeId(connNode, red, ctx, output)
+		err = redis.SetNodeIdLocked(lock, connNode, red, ctx, output)
 		if err != nil {
 			log.Error("Error SetNodeId: ", err)
 			return "", err
 		}
+		recordPodEvent(cluster, namespace, connNode, corev1.EventTypeNormal, k8s.EventIdentityGenerated,
+			"Node ID generated: [%s]", output)
+		AnnotateIdentity(ctx, namespace, connNode, output, "")
 	} else {
-		log.Error("Output is empty for pod: ", " [", pod.NodeName, "] ")
-		return "", err
+		log.Error("Output is empty for pod: ", " [", connNode, "] ")
+		return "", fmt.Errorf("%w: [%s]", ErrEmptyNodeId, connNode)
 	}
 
 	return output, nil
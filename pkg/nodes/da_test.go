@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
 )
 
 func TestHasAddrAlready(t *testing.T) {
@@ -113,6 +114,7 @@ func TestSetDaNodeDefault(t *testing.T) {
 				},
 			},
 			want: config.Peer{
+				Namespace:          k8s.GetCurrentNamespace(),
 				NodeName:           "da-full-1",
 				NodeType:           "da",
 				ContainerName:      "da",
@@ -133,6 +135,7 @@ func TestSetDaNodeDefault(t *testing.T) {
 				},
 			},
 			want: config.Peer{
+				Namespace:          k8s.GetCurrentNamespace(),
 				NodeName:           "da-bridge-1",
 				NodeType:           "da",
 				ContainerName:      "da",
@@ -208,3 +211,48 @@ func TestTruncateString(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateNodeId(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodeType string
+		id       string
+		wantErr  bool
+	}{
+		{
+			name:     "Case 1: Valid DA node ID",
+			nodeType: "da",
+			id:       "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W",
+		},
+		{
+			name:     "Case 2: DA node ID too short",
+			nodeType: "da",
+			id:       "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg",
+			wantErr:  true,
+		},
+		{
+			name:     "Case 3: DA node ID with the length of a valid one and a multi address injected",
+			nodeType: "da",
+			id:       "12D3KooWPB3thXCYyr6Jid49d5DDaRL63,/ip4/6.6.6.6/tcp/1",
+			wantErr:  true,
+		},
+		{
+			name:     "Case 4: Valid consensus node ID",
+			nodeType: "consensus",
+			id:       "e9b6c9bb7b1e1f0a6e3d4a5b6c7d8e9f0a1b2c3d",
+		},
+		{
+			name:     "Case 5: Consensus node ID that is not hex encoded",
+			nodeType: "consensus",
+			id:       "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVaga",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateNodeId(tt.nodeType, tt.id); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNodeId() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+// deliveryNamePrefix prefix of the ConfigMaps and Secrets created for every node.
+const deliveryNamePrefix = "torch-"
+
+// ErrInvalidDeliveryMode is returned when the delivery mode of the peer is not exec, configMap or secret.
+var ErrInvalidDeliveryMode = errors.New("invalid delivery mode, must be exec, configMap or secret")
+
+// DeliveryMode returns how the connections are delivered to the node, exec by default.
+func DeliveryMode(peer config.Peer) string {
+	if peer.Delivery.Mode == "" {
+		return k8s.DeliveryExec
+	}
+	return peer.Delivery.Mode
+}
+
+// DeliveryName returns the name of the ConfigMap or the Secret of the node, by default every node has its own.
+func DeliveryName(peer config.Peer) string {
+	if peer.Delivery.Name != "" {
+		return peer.Delivery.Name
+	}
+	return deliveryNamePrefix + peer.NodeName
+}
+
+// DeliveryKey returns the key of the ConfigMap or the Secret where the content of the file is stored, the name of the
+// file when the node has its own, and the name of the node when it is shared with the rest of the network.
+func DeliveryKey(peer config.Peer, file string) string {
+	switch {
+	case peer.Delivery.Key != "":
+		return peer.Delivery.Key
+	case peer.Delivery.Name != "":
+		return peer.NodeName
+	}
+	return path.Base(file)
+}
+
+// WriteNodeFile delivers the content of the file to the node holding the lock of the node, so no one else writes it
+// at the same time. The file is written using the container specified, or stored in the ConfigMap or the Secret of
+// the node depending on its delivery mode, in the cluster of the node.
+func WriteNodeFile(
+	ctx context.Context,
+	red *redis.RedisClient,
+	peer config.Peer,
+	container, file, content string,
+) error {
+	return WithNodeLock(ctx, red, peer.NodeName, func(lock *redis.Lock) error {
+		if err := lock.Validate(ctx); err != nil {
+			return err
+		}
+
+		ctx := k8s.WithCluster(ctx, peer.Cluster)
+		namespace := peer.Namespace
+		mode := DeliveryMode(peer)
+
+		var err error
+		switch mode {
+		case k8s.DeliveryExec:
+			// the files are written in the pods of the namespace watched in the cluster of the node.
+			namespace = k8s.ClusterNamespace(peer.Cluster)
+			err = k8s.WriteFile(ctx, peer.NodeName, container, namespace, file, content)
+		case k8s.DeliveryConfigMap:
+			err = k8s.SetConfigMapKey(ctx, peer.Namespace, DeliveryName(peer), DeliveryKey(peer, file), content)
+		case k8s.DeliverySecret:
+			err = k8s.SetSecretKey(ctx, peer.Namespace, DeliveryName(peer), DeliveryKey(peer, file), content)
+		default:
+			err = ErrInvalidDeliveryMode
+		}
+		if err != nil {
+			log.Error("Error delivering the file: [", file, "] to the node: [", peer.NodeName, "]: ", err)
+			return err
+		}
+
+		// the content is not included, the events can be read by more users than the Secrets.
+		recordPodEvent(peer.Cluster, namespace, peer.NodeName, corev1.EventTypeNormal, k8s.EventPeersWritten,
+			"Connections delivered to [%s] using [%s]", file, mode)
+		return nil
+	})
+}
+
+// ReadNodeFile returns the content of the file delivered to the node, reading it from the container specified, or
+// from the ConfigMap or the Secret of the node depending on its delivery mode.
+func ReadNodeFile(ctx context.Context, peer config.Peer, container, file string) (string, error) {
+	ctx = k8s.WithCluster(ctx, peer.Cluster)
+	switch DeliveryMode(peer) {
+	case k8s.DeliveryExec:
+		return k8s.ReadRemoteFile(ctx, peer.NodeName, container, k8s.ClusterNamespace(peer.Cluster), file)
+	case k8s.DeliveryConfigMap:
+		return k8s.GetConfigMapKey(ctx, peer.Namespace, DeliveryName(peer), DeliveryKey(peer, file))
+	case k8s.DeliverySecret:
+		return k8s.GetSecretKey(ctx, peer.Namespace, DeliveryName(peer), DeliveryKey(peer, file))
+	}
+	return "", ErrInvalidDeliveryMode
+}
@@ -0,0 +1,65 @@
+package nodes
+
+import (
+	"context"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+// ExternalMultiAddrField field of the metadata of the node with its external multi address.
+const ExternalMultiAddrField = "external_multiaddr"
+
+// ExternalMultiAddr returns the multi address of the node id using the address published by the Load Balancer,
+// /ip4/ or /ip6/ for the IPs and /dns4/ for the hostnames.
+func ExternalMultiAddr(endpoint k8s.ExternalEndpoint, id string) string {
+	if endpoint.IP != "" {
+		return ipMultiAddr(endpoint.IP, endpoint.Port, id)
+	}
+	return "/dns4/" + endpoint.Hostname + "/tcp/" + strconv.Itoa(int(endpoint.Port)) + "/p2p/" + id
+}
+
+// UpdateExternalMultiAddr generates the external multi address of the node using the Load Balancer of its external
+// service in the cluster of the node and stores it in the metadata of the node. It returns an empty value for the nodes without external service.
+func UpdateExternalMultiAddr(ctx context.Context, red *redis.RedisClient, peer config.Peer, id string) (string, error) {
+	if peer.ExternalService == "" || id == "" {
+		return "", nil
+	}
+
+	namespace := peer.Namespace
+	if namespace == "" {
+		namespace = k8s.ClusterNamespace(peer.Cluster)
+	}
+
+	endpoint, err := k8s.GetLoadBalancerEndpoint(k8s.WithCluster(ctx, peer.Cluster), namespace, peer.ExternalService, p2pPort)
+	if err != nil {
+		log.Warn("Error getting the Load Balancer of the service: [", peer.ExternalService, "]: ", err)
+		return "", err
+	}
+
+	ma := ExternalMultiAddr(endpoint, id)
+	if err := red.SetHashField(ctx, redis.NodeMetadataKey(peer.NodeName), ExternalMultiAddrField, ma); err != nil {
+		log.Error("Error storing the external multi address of the node: [", peer.NodeName, "]: ", err)
+		return "", err
+	}
+
+	log.Info("External MultiAddr for node ", peer.NodeName, " is: [", ma, "]")
+	metrics.RegisterExternalMultiAddr(metrics.ExternalMultiAddr{
+		NodeName:    peer.NodeName,
+		Namespace:   namespace,
+		ServiceName: peer.ExternalService,
+		MultiAddr:   ma,
+	})
+	return ma, nil
+}
+
+// GetExternalMultiAddr returns the external multi address stored in the metadata of the node, empty if it doesn't
+// have one.
+func GetExternalMultiAddr(ctx context.Context, red *redis.RedisClient, nodeName string) (string, error) {
+	return red.GetHashField(ctx, redis.NodeMetadataKey(nodeName), ExternalMultiAddrField)
+}
@@ -0,0 +1,86 @@
+package nodes
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+func TestExternalMultiAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint k8s.ExternalEndpoint
+		want     string
+	}{
+		{
+			name:     "Case 1: Load Balancer with IPv4",
+			endpoint: k8s.ExternalEndpoint{IP: "203.0.113.1", Port: 2121},
+			want:     "/ip4/203.0.113.1/tcp/2121/p2p/" + testNodeID,
+		},
+		{
+			name:     "Case 2: Load Balancer with IPv6",
+			endpoint: k8s.ExternalEndpoint{IP: "2001:db8::1", Port: 2121},
+			want:     "/ip6/2001:db8::1/tcp/2121/p2p/" + testNodeID,
+		},
+		{
+			name:     "Case 3: Load Balancer with hostname",
+			endpoint: k8s.ExternalEndpoint{Hostname: "lb.example.com", Port: 30000},
+			want:     "/dns4/lb.example.com/tcp/30000/p2p/" + testNodeID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExternalMultiAddr(tt.endpoint, testNodeID); got != tt.want {
+				t.Errorf("ExternalMultiAddr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateExternalMultiAddr(t *testing.T) {
+	_, red, _ := newTestEnv(t)
+	ctx := context.Background()
+
+	k8s.SetClientSet(k8sfake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-1-lb", Namespace: "celestia"},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Name: "p2p", Port: 2121, Protocol: corev1.ProtocolTCP}},
+		},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+			Ingress: []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}},
+		}},
+	}))
+
+	peer := config.Peer{NodeName: "da-bridge-1-0", NodeType: "da", Namespace: "celestia", ExternalService: "da-bridge-1-lb"}
+	want := "/dns4/lb.example.com/tcp/2121/p2p/" + testNodeID
+
+	// Case 1: the external multi address is stored in the metadata of the node.
+	got, err := UpdateExternalMultiAddr(ctx, red, peer, testNodeID)
+	if err != nil || got != want {
+		t.Fatalf("UpdateExternalMultiAddr() = %v, %v, want %v", got, err, want)
+	}
+	stored, err := GetExternalMultiAddr(ctx, red, peer.NodeName)
+	if err != nil || stored != want {
+		t.Errorf("GetExternalMultiAddr() = %v, %v, want %v", stored, err, want)
+	}
+
+	// Case 2: the nodes without external service don't have one.
+	peer.NodeName, peer.ExternalService = "da-full-1-0", ""
+	if got, err := UpdateExternalMultiAddr(ctx, red, peer, testNodeID); err != nil || got != "" {
+		t.Errorf("UpdateExternalMultiAddr() = %v, %v, want empty", got, err)
+	}
+
+	// Case 3: the Load Balancer without address returns an error.
+	peer.ExternalService = "missing"
+	if _, err := UpdateExternalMultiAddr(ctx, red, peer, testNodeID); err == nil {
+		t.Error("UpdateExternalMultiAddr() expected an error for a missing service")
+	}
+}
@@ -0,0 +1,590 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adjust/rmq/v5"
+	"github.com/alicebob/miniredis/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/k8s/fake"
+)
+
+// testNodeID id returned by the bridge nodes in the tests.
+const testNodeID = "12D3KooWNFpkX9fuo3GQ38FaVKdAZcTQsLr1BNE5DTHGjv2fjEHG"
+
+// newTestEnv points Torch to a miniredis server, replaces the executor with a fake one that answers the scripts of
+// the bridge nodes and the clientSet with a fake one with the pod of a bridge node and the running pods of the nodes
+// that connect to it, the events are recorded in a fake recorder.
+func newTestEnv(t *testing.T) (*fake.Executor, *redis.RedisClient, *record.FakeRecorder) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	t.Setenv("REDIS_HOST", server.Host())
+	t.Setenv("REDIS_PORT", server.Port())
+
+	executor := fake.NewExecutor()
+	executor.On("sh", func(k8s.ExecRequest, string) (k8s.ExecResult, error) {
+		return k8s.ExecResult{Stdout: testNodeID}, nil
+	})
+	k8s.SetExecutor(executor)
+	t.Cleanup(func() { k8s.SetExecutor(nil) })
+
+	bridge := fake.RunningPod("da-bridge-1-0", k8s.GetCurrentNamespace(), daContainerName)
+	bridge.Status.HostIP = "192.168.0.1"
+	bridge.Status.PodIPs = []corev1.PodIP{{IP: "10.0.0.1"}, {IP: "fd00::1"}}
+	k8s.SetClientSet(k8sfake.NewSimpleClientset(append(nodePods(), bridge)...))
+	t.Cleanup(func() { k8s.SetClientSet(nil) })
+
+	recorder := record.NewFakeRecorder(100)
+	k8s.SetEventRecorder(recorder)
+	t.Cleanup(func() { k8s.SetEventRecorder(nil) })
+
+	return executor, redis.InitRedisConfig(), recorder
+}
+
+// nodePods returns the running pods of the nodes that connect to the bridge node.
+func nodePods() []runtime.Object {
+	namespace := k8s.GetCurrentNamespace()
+	return []runtime.Object{
+		fake.RunningPod("da-full-1-0", namespace, daContainerSetupName, daContainerName),
+		fake.RunningPod("da-full-2-0", namespace, daContainerSetupName, daContainerName),
+		fake.RunningPod("da-full-3-0", namespace, daContainerSetupName, daContainerName),
+		fake.RunningPod("consensus-full-1-0", namespace, consContainerSetupName),
+	}
+}
+
+func TestSetupDANodeWithConnections(t *testing.T) {
+	tests := []struct {
+		name string
+		peer config.Peer
+		want string
+	}{
+		{
+			name: "Case 1: Connection using DNS",
+			peer: config.Peer{
+				NodeName:       "da-full-1-0",
+				NodeType:       "da",
+				DnsConnections: []string{"da-bridge-1"},
+				ConnectsTo:     []string{"da-bridge-1-0"},
+			},
+			want: "/dns/da-bridge-1/tcp/2121/p2p/" + testNodeID,
+		},
+		{
+			name: "Case 2: Connection using the IPs of the pod",
+			peer: config.Peer{
+				NodeName:   "da-full-2-0",
+				NodeType:   "da",
+				ConnectsTo: []string{"da-bridge-1-0"},
+			},
+			want: "/ip4/10.0.0.1/tcp/2121/p2p/" + testNodeID + ",/ip6/fd00::1/tcp/2121/p2p/" + testNodeID,
+		},
+		{
+			name: "Case 3: Connection using the IP of the host",
+			peer: config.Peer{
+				NodeName:   "da-full-3-0",
+				NodeType:   "da",
+				IPSource:   k8s.IPSourceHost,
+				ConnectsTo: []string{"da-bridge-1-0"},
+			},
+			want: "/ip4/192.168.0.1/tcp/2121/p2p/" + testNodeID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor, red, recorder := newTestEnv(t)
+			ctx := context.Background()
+
+			if err := SetupDANodeWithConnections(SetDaNodeDefault(tt.peer)); err != nil {
+				t.Fatalf("SetupDANodeWithConnections() error = %v", err)
+			}
+
+			if got, _ := executor.File(k8s.GetCurrentNamespace(), tt.peer.NodeName, fPathDA); got != tt.want {
+				t.Errorf("file = %v, want %v", got, tt.want)
+			}
+			if _, ok := executor.File(k8s.GetCurrentNamespace(), tt.peer.NodeName, fPathDA+".torch-tmp"); ok {
+				t.Errorf("temporary file not renamed")
+			}
+			if id, _ := red.GetKey(ctx, "da-bridge-1-0"); id != testNodeID {
+				t.Errorf("GetKey() = %v, want %v", id, testNodeID)
+			}
+			if tasks, _ := red.ListRetries(ctx); len(tasks) != 1 || tasks[0].Peer.NodeName != tt.peer.NodeName {
+				t.Errorf("ListRetries() = %v, want the node in the queue", tasks)
+			}
+			expectEvents(t, recorder, "Normal "+k8s.EventIdentityGenerated, "Normal "+k8s.EventPeersWritten)
+		})
+	}
+}
+
+func TestSetupDANodeWithoutConnections(t *testing.T) {
+	executor, red, _ := newTestEnv(t)
+	ctx := context.Background()
+	// the init container of the bridge has already finished, so any remote command would fail.
+	bridge := fake.RunningPod("da-bridge-1-0", k8s.GetCurrentNamespace(), daContainerName)
+	bridge.Spec.InitContainers = []corev1.Container{{Name: daContainerSetupName}}
+	bridge.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+		Name:  daContainerSetupName,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+	}}
+	k8s.SetClientSet(k8sfake.NewSimpleClientset(bridge))
+
+	if err := SetupDANodeWithConnections(SetDaNodeDefault(config.Peer{NodeName: "da-bridge-1-0", NodeType: "da"})); err != nil {
+		t.Fatalf("SetupDANodeWithConnections() error = %v", err)
+	}
+	if calls := executor.Calls(); len(calls) != 0 {
+		t.Errorf("Calls() = %v, want no remote commands", calls)
+	}
+	if tasks, _ := red.ListRetries(ctx); len(tasks) != 0 {
+		t.Errorf("ListRetries() = %v, want no nodes in the queue", tasks)
+	}
+}
+
+func TestSetupDANodeWithConnectionsErrors(t *testing.T) {
+	peer := SetDaNodeDefault(config.Peer{
+		NodeName:       "da-full-1-0",
+		NodeType:       "da",
+		DnsConnections: []string{"da-bridge-1"},
+		ConnectsTo:     []string{"da-bridge-1-0"},
+	})
+
+	// Case 1: the script of the bridge node fails.
+	executor, _, recorder := newTestEnv(t)
+	executor.On("sh", func(k8s.ExecRequest, string) (k8s.ExecResult, error) {
+		return k8s.ExecResult{Stderr: "connection refused", ExitCode: 1}, nil
+	})
+	if err := SetupDANodeWithConnections(peer); !errors.Is(err, k8s.ErrCommandFailed) {
+		t.Errorf("SetupDANodeWithConnections() error = %v, want %v", err, k8s.ErrCommandFailed)
+	}
+	expectEvents(t, recorder, "Warning "+k8s.EventExecFailed)
+
+	// Case 2: the content of the file written doesn't match.
+	executor, _, _ = newTestEnv(t)
+	executor.On("sha256sum", func(k8s.ExecRequest, string) (k8s.ExecResult, error) {
+		return k8s.ExecResult{Stdout: "0000  " + fPathDA}, nil
+	})
+	if err := SetupDANodeWithConnections(peer); !errors.Is(err, k8s.ErrChecksumMismatch) {
+		t.Errorf("SetupDANodeWithConnections() error = %v, want %v", err, k8s.ErrChecksumMismatch)
+	}
+}
+
+func TestSetupNodesEnvVarAndConnections(t *testing.T) {
+	executor, _, _ := newTestEnv(t)
+	peer := SetConsNodeDefault(config.Peer{
+		NodeName:         "consensus-full-1-0",
+		NodeType:         "consensus",
+		ConnectsAsEnvVar: true,
+		ConnectsTo:       []string{`consensus-validator-1"; $(id)`},
+	})
+
+	if err := SetupNodesEnvVarAndConnections(peer, config.MutualPeersConfig{}); err != nil {
+		t.Fatalf("SetupNodesEnvVarAndConnections() error = %v", err)
+	}
+
+	// Case 1: the value is written as it is, without being interpreted.
+	file := k8s.EnvVarFilePath("consensus")
+	if got, _ := executor.File(k8s.GetCurrentNamespace(), peer.NodeName, file); got != peer.ConnectsTo[0] {
+		t.Errorf("file = %v, want %v", got, peer.ConnectsTo[0])
+	}
+
+	// Case 2: the file is written using the setup container without a shell.
+	for _, call := range executor.Calls() {
+		if call.Container != consContainerSetupName || call.Command[0] == "sh" {
+			t.Errorf("unexpected command: %v in the container: %v", call.Command, call.Container)
+		}
+	}
+}
+
+func TestSetupDANodeWithConnectionsConfigMap(t *testing.T) {
+	executor, _, _ := newTestEnv(t)
+	client := k8sfake.NewSimpleClientset(fake.RunningPod("da-bridge-1-0", k8s.GetCurrentNamespace(), daContainerName))
+	k8s.SetClientSet(client)
+	t.Cleanup(func() { k8s.SetClientSet(nil) })
+
+	peer := SetDaNodeDefault(config.Peer{
+		NodeName:       "da-full-1-0",
+		NodeType:       "da",
+		DnsConnections: []string{"da-bridge-1"},
+		ConnectsTo:     []string{"da-bridge-1-0"},
+		Delivery:       config.Delivery{Mode: k8s.DeliveryConfigMap, Name: "torch-peers"},
+	})
+	if err := SetupDANodeWithConnections(peer); err != nil {
+		t.Fatalf("SetupDANodeWithConnections() error = %v", err)
+	}
+
+	// Case 1: the connections are stored in the ConfigMap shared by the network, keyed by node name.
+	configMap, err := client.CoreV1().ConfigMaps(peer.Namespace).Get(context.Background(), "torch-peers", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got, want := configMap.Data[peer.NodeName], "/dns/da-bridge-1/tcp/2121/p2p/"+testNodeID; got != want {
+		t.Errorf("ConfigMap data = %v, want %v", got, want)
+	}
+
+	// Case 2: nothing is written in the containers of the node.
+	for _, call := range executor.Calls() {
+		if call.PodName == peer.NodeName {
+			t.Errorf("unexpected command: %v in the node: %v", call.Command, call.PodName)
+		}
+	}
+}
+
+func TestSetupDANodeWithConnectionsSecret(t *testing.T) {
+	_, _, recorder := newTestEnv(t)
+	client := k8sfake.NewSimpleClientset(fake.RunningPod("da-bridge-1-0", k8s.GetCurrentNamespace(), daContainerName))
+	k8s.SetClientSet(client)
+	t.Cleanup(func() { k8s.SetClientSet(nil) })
+
+	peer := SetDaNodeDefault(config.Peer{
+		NodeName:       "da-full-1-0",
+		NodeType:       "da",
+		DnsConnections: []string{"da-bridge-1"},
+		ConnectsTo:     []string{"da-bridge-1-0"},
+		Delivery:       config.Delivery{Mode: k8s.DeliverySecret, Name: "torch-peers"},
+	})
+	if err := SetupDANodeWithConnections(peer); err != nil {
+		t.Fatalf("SetupDANodeWithConnections() error = %v", err)
+	}
+
+	// Case 1: the connections are stored in the Secret.
+	want := "/dns/da-bridge-1/tcp/2121/p2p/" + testNodeID
+	secret, err := client.CoreV1().Secrets(peer.Namespace).Get(context.Background(), "torch-peers", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := string(secret.Data[peer.NodeName]); got != want {
+		t.Errorf("Secret data = %v, want %v", got, want)
+	}
+
+	// Case 2: the events don't include the content of the Secret.
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, want) {
+			t.Errorf("event = %v, want it without the content of the Secret", event)
+		}
+	}
+}
+
+// expectEvents checks that the events recorded start with the type and the reason expected, in order.
+func expectEvents(t *testing.T, recorder *record.FakeRecorder, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case event := <-recorder.Events:
+			if !strings.HasPrefix(event, w) {
+				t.Errorf("event = %v, want %v", event, w)
+			}
+		default:
+			t.Errorf("event %v not recorded", w)
+		}
+	}
+}
+
+func TestGenerateNodeIdFromPodAnnotations(t *testing.T) {
+	executor, red, _ := newTestEnv(t)
+	ctx := context.Background()
+
+	annotatedID := strings.Repeat("a", nodeIdMaxLength)
+	annotated := fake.RunningPod("da-bridge-1-0", k8s.GetCurrentNamespace(), daContainerName)
+	annotated.Annotations = map[string]string{k8s.PeerIDAnnotation: annotatedID}
+	// the annotation of the third bridge has the length of an id but it injects a multi address.
+	injected := fake.RunningPod("da-bridge-3-0", k8s.GetCurrentNamespace(), daContainerName)
+	injected.Annotations = map[string]string{k8s.PeerIDAnnotation: "12D3KooWPB3thXCYyr6Jid49d5DDaRL63,/ip4/6.6.6.6/tcp/1"}
+	client := k8sfake.NewSimpleClientset(
+		annotated,
+		fake.RunningPod("da-bridge-2-0", k8s.GetCurrentNamespace(), daContainerName),
+		injected,
+	)
+	k8s.SetClientSet(client)
+	t.Cleanup(func() { k8s.SetClientSet(nil) })
+	SetConfigVersion("v1")
+	t.Cleanup(func() { SetConfigVersion("") })
+
+	peer := SetDaNodeDefault(config.Peer{NodeName: "da-full-1-0", NodeType: "da"})
+
+	// Case 1: the id is restored from the annotations of the pod without running any command.
+	id, err := GenerateNodeIdAndSaveIt(peer, "da-bridge-1-0", red, ctx)
+	if err != nil || id != annotatedID {
+		t.Fatalf("GenerateNodeIdAndSaveIt() = %v, %v, want %v", id, err, annotatedID)
+	}
+	if got, _ := red.GetKey(ctx, "da-bridge-1-0"); got != annotatedID {
+		t.Errorf("GetKey() = %v, want %v", got, annotatedID)
+	}
+	if calls := executor.Calls(); len(calls) != 0 {
+		t.Errorf("unexpected commands: %v", calls)
+	}
+
+	// Case 2: the id generated is added to the annotations of the pod.
+	if _, err := GenerateNodeIdAndSaveIt(peer, "da-bridge-2-0", red, ctx); err != nil {
+		t.Fatalf("GenerateNodeIdAndSaveIt() error = %v", err)
+	}
+	pod, err := client.CoreV1().Pods(k8s.GetCurrentNamespace()).Get(ctx, "da-bridge-2-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if pod.Annotations[k8s.PeerIDAnnotation] != testNodeID || pod.Annotations[k8s.ConfigVersionAnnotation] != "v1" {
+		t.Errorf("annotations = %v, want the id and the config version", pod.Annotations)
+	}
+
+	// Case 3: the id is generated again when the annotation is not a valid id.
+	id, err = GenerateNodeIdAndSaveIt(peer, "da-bridge-3-0", red, ctx)
+	if err != nil || id != testNodeID {
+		t.Fatalf("GenerateNodeIdAndSaveIt() = %v, %v, want %v", id, err, testNodeID)
+	}
+	if got, _ := red.GetKey(ctx, "da-bridge-3-0"); got != testNodeID {
+		t.Errorf("GetKey() = %v, want %v", got, testNodeID)
+	}
+}
+
+func TestSetupDANodeWithConnectionsAcrossClusters(t *testing.T) {
+	executor, red, _ := newTestEnv(t)
+	ctx := context.Background()
+
+	k8s.SetClusterClientSet("remote", k8sfake.NewSimpleClientset(
+		fake.RunningPod("da-bridge-2-0", "celestia", daContainerName),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-2-lb", Namespace: "celestia"},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeLoadBalancer,
+				Ports: []corev1.ServicePort{{Name: "p2p", Port: 2121, Protocol: corev1.ProtocolTCP}},
+			},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "203.0.113.2"}},
+			}},
+		},
+	), "celestia")
+	t.Cleanup(func() { k8s.SetClusterClientSet("remote", nil, "") })
+
+	bridge := config.Peer{NodeName: "da-bridge-2-0", NodeType: "da", Cluster: "remote", ExternalService: "da-bridge-2-lb"}
+	peer := config.Peer{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-bridge-2-0"}}
+	SetPeers(config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{bridge, peer}}}})
+	t.Cleanup(func() { SetPeers(config.MutualPeersConfig{}) })
+
+	if err := SetupDANodeWithConnections(SetDaNodeDefault(peer)); err != nil {
+		t.Fatalf("SetupDANodeWithConnections() error = %v", err)
+	}
+
+	// Case 1: the node of the other cluster is reached using its Load Balancer.
+	want := "/ip4/203.0.113.2/tcp/2121/p2p/" + testNodeID
+	if got, _ := executor.File(k8s.GetCurrentNamespace(), peer.NodeName, fPathDA); got != want {
+		t.Errorf("file = %v, want %v", got, want)
+	}
+	if got, _ := GetExternalMultiAddr(ctx, red, bridge.NodeName); got != want {
+		t.Errorf("GetExternalMultiAddr() = %v, want %v", got, want)
+	}
+
+	// Case 2: the id is generated in the cluster and the namespace of the bridge, the file in the local one.
+	for _, call := range executor.Calls() {
+		wantCluster, wantNamespace := "", k8s.GetCurrentNamespace()
+		if call.PodName == bridge.NodeName {
+			wantCluster, wantNamespace = "remote", "celestia"
+		}
+		if call.Cluster != wantCluster || call.Namespace != wantNamespace {
+			t.Errorf("command %v in the pod %v sent to %v/%v, want %v/%v",
+				call.Command, call.PodName, call.Cluster, call.Namespace, wantCluster, wantNamespace)
+		}
+	}
+}
+
+func TestStoredConnectionString(t *testing.T) {
+	executor, red, _ := newTestEnv(t)
+	ctx := context.Background()
+
+	peer := SetDaNodeDefault(config.Peer{
+		NodeName:   "da-full-1-0",
+		NodeType:   "da",
+		ConnectsTo: []string{"da-bridge-1-0", "/dns/da-bridge-2/tcp/2121/p2p/" + testNodeID},
+	})
+
+	// Case 1: the id of the bridge is not stored yet and it is not generated.
+	if _, err := StoredConnectionString(ctx, red, peer); !errors.Is(err, ErrConnectionNotStored) {
+		t.Errorf("StoredConnectionString() error = %v, want %v", err, ErrConnectionNotStored)
+	}
+	if calls := executor.Calls(); len(calls) != 0 {
+		t.Errorf("unexpected commands: %v", calls)
+	}
+
+	// Case 2: the ids stored are prefixed with the IPs of the pod, the addresses of the config are kept.
+	if err := redis.SetNodeId("da-bridge-1-0", red, ctx, testNodeID); err != nil {
+		t.Fatalf("SetNodeId() error = %v", err)
+	}
+	want := "/ip4/10.0.0.1/tcp/2121/p2p/" + testNodeID + ",/ip6/fd00::1/tcp/2121/p2p/" + testNodeID +
+		",/dns/da-bridge-2/tcp/2121/p2p/" + testNodeID
+	if got, err := StoredConnectionString(ctx, red, peer); err != nil || got != want {
+		t.Errorf("StoredConnectionString() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestRejectOrRetry(t *testing.T) {
+	executor, red, _ := newTestEnv(t)
+	ctx := context.Background()
+	namespace := k8s.GetCurrentNamespace()
+	t.Setenv("CONTAINER_READY_TIMEOUT", "10ms")
+	baseDelay, maxDelay := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = 0, 0
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = baseDelay, maxDelay })
+
+	// the containers of the node are waiting to start.
+	pod := fake.RunningPod("da-full-1-0", namespace, daContainerSetupName, daContainerName)
+	for i := range pod.Status.ContainerStatuses {
+		pod.Status.ContainerStatuses[i].State = corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}
+	}
+	client := k8sfake.NewSimpleClientset(fake.RunningPod("da-bridge-1-0", namespace, daContainerName), pod)
+	k8s.SetClientSet(client)
+
+	peer := SetDaNodeDefault(config.Peer{
+		NodeName:       "da-full-1-0",
+		NodeType:       "da",
+		DnsConnections: []string{"da-bridge-1"},
+		ConnectsTo:     []string{"da-bridge-1-0"},
+	})
+
+	// Case 1: the node whose container is not ready is added to the retry queue with its setup.
+	delivery := rmq.NewTestDeliveryString("da-full-1-0")
+	rejectOrRetry(delivery, peer, true, fmt.Errorf("%w: container [da-setup]", k8s.ErrContainerNotReady))
+	if delivery.State != rmq.Acked {
+		t.Errorf("delivery state = %v, want %v", delivery.State, rmq.Acked)
+	}
+	if tasks, _ := red.ListRetries(ctx); len(tasks) != 1 || tasks[0].Peer.NodeName != peer.NodeName || !tasks[0].Setup {
+		t.Fatalf("ListRetries() = %v, want the node in the queue with its setup", tasks)
+	}
+
+	// Case 2: the retry fails while the container is not ready, keeping the setup of the node.
+	processQueue()
+	if tasks, _ := red.ListRetries(ctx); len(tasks) != 1 || tasks[0].Attempt != 1 || !tasks[0].Setup {
+		t.Fatalf("ListRetries() = %v, want the node retried with its setup", tasks)
+	}
+	if _, ok := executor.File(namespace, peer.NodeName, fPathDA); ok {
+		t.Errorf("file written before the container is running")
+	}
+
+	// Case 3: the connections are written once the container is running.
+	if _, err := client.CoreV1().Pods(namespace).Update(ctx,
+		fake.RunningPod("da-full-1-0", namespace, daContainerSetupName, daContainerName), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	processQueue()
+	if got, ok := executor.File(namespace, peer.NodeName, fPathDA); !ok || got != "/dns/da-bridge-1/tcp/2121/p2p/"+testNodeID {
+		t.Errorf("file = %v, want the connections of the node", got)
+	}
+	if tasks, _ := red.ListRetries(ctx); len(tasks) != 0 {
+		t.Errorf("ListRetries() = %v, want the task completed", tasks)
+	}
+
+	// Case 4: the rest of the errors reject the delivery.
+	delivery = rmq.NewTestDeliveryString("da-full-1-0")
+	rejectOrRetry(delivery, peer, true, k8s.ErrCommandFailed)
+	if delivery.State != rmq.Rejected {
+		t.Errorf("delivery state = %v, want %v", delivery.State, rmq.Rejected)
+	}
+}
+
+func TestProcessTaskMaxRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		cluster   string
+		wantEvent bool
+	}{
+		{
+			name:      "Case 1: the event is attached to the pod of the cluster where Torch runs",
+			wantEvent: true,
+		},
+		{
+			name:    "Case 2: the event of the pod of a remote cluster is not attached to the local pod",
+			cluster: "remote",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor, red, recorder := newTestEnv(t)
+			executor.On("sh", func(k8s.ExecRequest, string) (k8s.ExecResult, error) {
+				return k8s.ExecResult{Stderr: "error", ExitCode: 1}, nil
+			})
+
+			peer := config.Peer{NodeName: "da-bridge-1-0", NodeType: "da", Cluster: tt.cluster, RetryCount: 1}
+			processTask(red, redis.RetryTask{Peer: peer})
+
+			found := false
+			for len(recorder.Events) > 0 {
+				if event := <-recorder.Events; strings.Contains(event, k8s.EventMaxRetriesReached) {
+					found = true
+				}
+			}
+			if found != tt.wantEvent {
+				t.Errorf("event %v recorded = %v, want %v", k8s.EventMaxRetriesReached, found, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestConsumeInjected(t *testing.T) {
+	tests := []struct {
+		name     string
+		injected bool
+		wantFile bool
+	}{
+		{
+			name:     "Case 1: the connections declared by the pod are written",
+			wantFile: true,
+		},
+		{
+			name:     "Case 2: the connections injected by the webhook are not written",
+			injected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor, red, _ := newTestEnv(t)
+			payload, err := json.Marshal(redis.NodeTask{
+				NodeName:   "da-full-1-0",
+				NodeType:   "da",
+				ConnectsTo: []string{"da-bridge-1-0"},
+				Injected:   tt.injected,
+			})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			delivery := rmq.NewTestDeliveryString(string(payload))
+			consume(delivery, red, config.MutualPeersConfig{})
+			if delivery.State != rmq.Acked {
+				t.Errorf("delivery state = %v, want %v", delivery.State, rmq.Acked)
+			}
+			if _, ok := executor.File(k8s.GetCurrentNamespace(), "da-full-1-0", fPathDA); ok != tt.wantFile {
+				t.Errorf("file written = %v, want %v", ok, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestLogErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		logErrors(ctx, errChan)
+		close(done)
+	}()
+
+	// the errors are logged until the context is done, even if the channel is never closed.
+	errChan <- errors.New("redis is down")
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logErrors() didn't return after the context was done")
+	}
+}
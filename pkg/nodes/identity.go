@@ -0,0 +1,86 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+const (
+	consensusNodeIdLength = 40                                                           // consensusNodeIdLength length of the ids of the consensus nodes, hex encoded.
+	base58Alphabet        = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz" // base58Alphabet characters of the ids of the DA nodes.
+	hexAlphabet           = "0123456789abcdef"                                           // hexAlphabet characters of the ids of the consensus nodes.
+)
+
+// ErrInvalidNodeId is returned when the id of the node doesn't have the format of its type.
+var ErrInvalidNodeId = errors.New("the node id is not valid")
+
+// configVersion version of the config added to the annotations of the pods.
+var configVersion atomic.Value
+
+// SetConfigVersion sets the version of the config added to the annotations of the pods.
+func SetConfigVersion(version string) {
+	configVersion.Store(version)
+}
+
+// AnnotateIdentity adds the id and the multi address of the node to the annotations of its pod, so they are visible
+// with kubectl and they can be restored if the DB loses them. The empty values are not added.
+func AnnotateIdentity(ctx context.Context, namespace, nodeName, id, multiAddr string) {
+	annotations := map[string]string{}
+	if id != "" {
+		annotations[k8s.PeerIDAnnotation] = id
+	}
+	if multiAddr != "" {
+		annotations[k8s.MultiAddrAnnotation] = multiAddr
+	}
+	if version, ok := configVersion.Load().(string); ok && version != "" {
+		annotations[k8s.ConfigVersionAnnotation] = version
+	}
+
+	if err := k8s.AnnotatePod(ctx, namespace, nodeName, annotations); err != nil {
+		log.Warn("Error annotating the pod: [", nodeName, "]: ", err)
+	}
+}
+
+// IdentityFromPod returns the id of the node stored in the annotations of its pod, empty if the pod doesn't have it or
+// if it doesn't have the format of the ids of the node type, so the id is generated again instead of trusting a value
+// that anyone able to patch the pod could set.
+func IdentityFromPod(ctx context.Context, namespace, nodeName, nodeType string) string {
+	id, err := k8s.GetPodAnnotation(ctx, namespace, nodeName, k8s.PeerIDAnnotation)
+	if err != nil {
+		log.Warn("Error getting the id of the pod: [", nodeName, "]: ", err)
+		return ""
+	}
+	if id == "" {
+		return ""
+	}
+
+	if err := ValidateNodeId(nodeType, id); err != nil {
+		log.Warn("Ignoring the id in the annotations of the pod: [", nodeName, "]: ", err)
+		return ""
+	}
+	return id
+}
+
+// ValidateNodeId checks that the id has the length and the characters of the ids of the node type: base58 peer ids
+// for the DA nodes and hex encoded ids for the consensus nodes.
+func ValidateNodeId(nodeType, id string) error {
+	length, alphabet := nodeIdMaxLength, base58Alphabet
+	if nodeType == "consensus" {
+		length, alphabet = consensusNodeIdLength, hexAlphabet
+	}
+
+	if len(id) != length {
+		return fmt.Errorf("%w: [%s], length [%d], want [%d]", ErrInvalidNodeId, id, len(id), length)
+	}
+	if i := strings.IndexFunc(id, func(r rune) bool { return !strings.ContainsRune(alphabet, r) }); i >= 0 {
+		return fmt.Errorf("%w: [%s], invalid character at [%d]", ErrInvalidNodeId, id, i)
+	}
+	return nil
+}
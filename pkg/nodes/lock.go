@@ -0,0 +1,82 @@
+package nodes
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+const (
+	nodeLockTTL         = 2 * time.Minute // nodeLockTTL time to keep the lock of a node if the holder stops refreshing it.
+	nodeLockRefresh     = nodeLockTTL / 3 // nodeLockRefresh how often the holder refreshes the lock of a node.
+	nodeLockReleaseTime = 5 * time.Second // nodeLockReleaseTime max time to release the lock of a node.
+)
+
+// inflight deduplicates the concurrent operations against the same node, the callers share the result.
+var inflight singleflight.Group
+
+// WithNodeLock runs fn while holding the distributed lock of the node, so the operations against the same node
+// are serialized across all the Torch replicas. The lock is refreshed until fn returns.
+func WithNodeLock(
+	ctx context.Context,
+	red *redis.RedisClient,
+	nodeName string,
+	fn func(lock *redis.Lock) error,
+) error {
+	lock, err := red.AcquireLock(ctx, nodeName, nodeLockTTL)
+	if err != nil {
+		log.Error("Error acquiring the lock for node: [", nodeName, "]: ", err)
+		return err
+	}
+	log.Info("Lock acquired for node: [", nodeName, "] - fencing token: [", lock.Fence, "]")
+
+	refreshCtx, stopRefresh := context.WithCancel(ctx)
+	go refreshNodeLock(refreshCtx, lock, nodeName)
+
+	defer func() {
+		stopRefresh()
+		// the context received might be done already, we still want to release the lock.
+		releaseCtx, cancel := context.WithTimeout(context.Background(), nodeLockReleaseTime)
+		defer cancel()
+		if err := lock.Release(releaseCtx); err != nil {
+			log.Warn("Error releasing the lock for node: [", nodeName, "]: ", err)
+		}
+	}()
+
+	return fn(lock)
+}
+
+// refreshNodeLock keeps the lock of the node alive until the context is done.
+func refreshNodeLock(ctx context.Context, lock *redis.Lock, nodeName string) {
+	ticker := time.NewTicker(nodeLockRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := lock.Refresh(ctx); err != nil {
+				log.Error("Error refreshing the lock for node: [", nodeName, "]: ", err)
+				return
+			}
+		}
+	}
+}
+
+// dedupe runs fn once for all the concurrent calls with the same key, all of them receive the same result.
+func dedupe(key string, fn func() (string, error)) (string, error) {
+	v, err, shared := inflight.Do(key, func() (interface{}, error) {
+		return fn()
+	})
+	if shared {
+		log.Info("Sharing the result of the operation in progress: [", key, "]")
+	}
+
+	output, _ := v.(string)
+	return output, err
+}
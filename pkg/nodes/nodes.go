@@ -1,9 +1,12 @@
 package nodes
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
 	"github.com/jrmanes/torch/pkg/k8s"
 )
 
@@ -27,15 +30,83 @@ func ValidateNode(n string, cfg config.MutualPeersConfig) (bool, config.Peer) {
 	return false, config.Peer{}
 }
 
+// SetNodeDefault sets the default values of the peer depending on its type.
+func SetNodeDefault(peer config.Peer) config.Peer {
+	switch peer.NodeType {
+	case "da":
+		peer = SetDaNodeDefault(peer)
+	case "consensus":
+		peer = SetConsNodeDefault(peer)
+	}
+	return peer
+}
+
+// ResolvePeer returns the peer of the task, using the one from the config if the node is there, otherwise a peer
+// with the values of the task and the default values of its type. The values declared by the pod are only used when
+// they are not defined in the config.
+func ResolvePeer(task redis.NodeTask, cfg config.MutualPeersConfig) config.Peer {
+	if task.Legacy && task.NodeType == "" {
+		task.NodeType = legacyNodeType(task.NodeName)
+	}
+	ok, peer := ValidateNode(task.NodeName, cfg)
+	if !ok {
+		log.Info("Pod [", task.NodeName, "] not found in the config, using the default values for type: [", task.NodeType, "]")
+		peer = config.Peer{
+			NodeName:  task.NodeName,
+			NodeType:  task.NodeType,
+			Namespace: task.Namespace,
+		}
+	}
+
+	if peer.NodeType == "" {
+		peer.NodeType = task.NodeType
+	}
+	if peer.Namespace == "" {
+		peer.Namespace = task.Namespace
+	}
+	if peer.ContainerName == "" {
+		peer.ContainerName = task.ContainerName
+	}
+	if peer.Cluster == "" {
+		peer.Cluster = task.Cluster
+	}
+	if len(peer.ConnectsTo) == 0 {
+		peer.ConnectsTo = task.ConnectsTo
+	}
+
+	return SetNodeDefault(peer)
+}
+
+// legacyNodeType returns the type of the node of the tasks published by previous versions of Torch, they only had the
+// name of the pod and they were always processed as DA nodes.
+func legacyNodeType(nodeName string) string {
+	if nodeType := k8s.NodeTypeFromName(nodeName); nodeType != "" {
+		return nodeType
+	}
+	return "da"
+}
+
 // SetupNodesEnvVarAndConnections configure the ENV vars for those nodes that needs to connect via ENV var
 func SetupNodesEnvVarAndConnections(peer config.Peer, cfg config.MutualPeersConfig) error {
-	// Configure Consensus & DA - connecting using env var
-	_, err := k8s.RunRemoteCommand(
-		peer.NodeName,
-		peer.ContainerSetupName,
-		k8s.GetCurrentNamespace(),
-		k8s.CreateFileWithEnvVar(peer.ConnectsTo[0], peer.NodeType),
-	)
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	// Configure Consensus & DA - connecting using env var, concurrent requests for the same node share the same
+	// execution and the lock makes sure that only one replica writes to the node at the same time.
+	_, err := dedupe("env/"+peer.NodeName, func() (string, error) {
+		return "", WriteNodeFile(
+			ctx,
+			red,
+			peer,
+			peer.ContainerSetupName,
+			k8s.EnvVarFilePath(peer.NodeType),
+			peer.ConnectsTo[0],
+		)
+	})
 	if err != nil {
 		log.Error("Error executing remote command: ", err)
 		return err
@@ -43,8 +114,7 @@ func SetupNodesEnvVarAndConnections(peer config.Peer, cfg config.MutualPeersConf
 
 	// check if the node is type DA, if so, add the node to the queue to generate the Multi Address later.
 	if peer.NodeType == "da" {
-		// we use the goroutine for that, otherwise, Torch tries to keep the connection opened.
-		go AddToQueue(peer)
+		AddToQueue(peer)
 	}
 
 	return nil
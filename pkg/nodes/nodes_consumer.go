@@ -2,9 +2,8 @@ package nodes
 
 import (
 	"context"
-	"os"
-	"os/signal"
-	"syscall"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/adjust/rmq/v5"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
 )
 
 const (
@@ -21,75 +21,135 @@ const (
 	timeoutDurationConsumer = 60 * time.Second // timeoutDurationConsumer timeout for the consumer.
 )
 
-// ConsumerInit initialize the process to check the queues in Redis.
-func ConsumerInit(queueName string) {
+// ConsumerInit initialize the process to check the queues in Redis, the nodes received are resolved against the config.
+// It returns when the context is done, after the deliveries being processed are finished.
+func ConsumerInit(ctx context.Context, queueName string, cfg config.MutualPeersConfig) error {
 	errChan := make(chan error, 10)
-	go logErrors(errChan)
+	go logErrors(ctx, errChan)
 
 	red := redis.InitRedisConfig()
-	// Create a new context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationConsumer)
-
-	// Make sure to call the cancel function to release resources when you're done
-	defer cancel()
 
-	connection, err := rmq.OpenConnection(
-		"consumer",
-		"tcp",
-		redis.GetRedisFullURL(),
-		2,
-		errChan,
-	)
+	connection, err := redis.OpenQueueConnection("consumer", errChan)
 	if err != nil {
 		log.Error("Error: ", err)
+		return err
 	}
+	// wait for all Consume() calls to finish
+	defer func() {
+		<-connection.StopAllConsuming()
+	}()
 
 	queue, err := connection.OpenQueue(queueName)
 	if err != nil {
 		log.Error("Error: ", err)
+		return err
 	}
 
-	if err := queue.StartConsuming(prefetchLimit, pollDuration); err != nil {
-		log.Error("Error: ", err)
+	// every consumer processes one delivery at a time, so we add one consumer per worker of the pool.
+	pool := GetPool("consumer-" + queueName)
+	prefetch := int64(prefetchLimit)
+	if int64(pool.Size()) > prefetch {
+		prefetch = int64(pool.Size())
 	}
 
-	_, err = queue.AddConsumerFunc(consumerName, func(delivery rmq.Delivery) {
-		log.Info("Performing task: ", delivery.Payload())
-		peer := config.Peer{
-			NodeName:      delivery.Payload(),
-			NodeType:      "da",
-			ContainerName: "da",
-		}
+	if err := queue.StartConsuming(prefetch, pollDuration); err != nil {
+		log.Error("Error: ", err)
+		return err
+	}
 
-		// here we wil send the node to generate the id
-		err := CheckNodesInDBOrCreateThem(peer, red, ctx)
+	for i := 0; i < pool.Size(); i++ {
+		_, err = queue.AddConsumerFunc(consumerName+"-"+strconv.Itoa(i), func(delivery rmq.Delivery) {
+			pool.Run(func() {
+				consume(delivery, red, cfg)
+			})
+		})
 		if err != nil {
-			log.Error("Error checking the nodes: CheckNodesInDBOrCreateThem - ", err)
+			log.Error("Error: ", err)
+			return err
 		}
+	}
 
-		if err := delivery.Ack(); err != nil {
+	<-ctx.Done()
+	log.Info("Stopping the consumer of the queue: [", queueName, "]")
+	return nil
+}
+
+// consume processes the delivery, it is acked if the node is processed successfully and rejected otherwise.
+func consume(delivery rmq.Delivery, red *redis.RedisClient, cfg config.MutualPeersConfig) {
+	log.Info("Performing task: ", delivery.Payload())
+	task, err := redis.ParseNodeTask(delivery.Payload())
+	if err != nil {
+		log.Error("Error decoding the task: ", err)
+		if err := delivery.Reject(); err != nil {
 			log.Error("Error: ", err)
 		}
-	})
+		return
+	}
+	peer := ResolvePeer(task, cfg)
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationConsumer)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	// the pods that declare their connections are configured without waiting for a request, unless the webhook already
+	// injected them into the pod.
+	setup := len(task.ConnectsTo) > 0 && peer.NodeType == "da" && !peer.ConnectsAsEnvVar && !task.Injected
+
+	// here we wil send the node to generate the id
+	err = CheckNodesInDBOrCreateThem(peer, red, ctx)
 	if err != nil {
-		log.Error("Error: ", err)
+		log.Error("Error checking the nodes: CheckNodesInDBOrCreateThem - ", err)
+		rejectOrRetry(delivery, peer, setup, err)
+		return
 	}
 
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT)
-	defer signal.Stop(signals)
+	if setup {
+		if err := SetupDANodeWithConnections(peer); err != nil {
+			log.Error("Error configuring the connections of the node: [", peer.NodeName, "]: ", err)
+			rejectOrRetry(delivery, peer, setup, err)
+			return
+		}
+	}
 
-	<-signals // wait for signal
-	go func() {
-		<-signals // hard exit on second signal (in case shutdown gets stuck)
-		os.Exit(1)
-	}()
+	if err := delivery.Ack(); err != nil {
+		log.Error("Error: ", err)
+	}
+}
 
-	<-connection.StopAllConsuming() // wait for all Consume() calls to finish
+// rejectOrRetry rejects the delivery, so it can be inspected and requeued with the queues API. When the container of
+// the node was not ready, the node is added to the retry queue instead, so it is retried with backoff, and when its
+// setup is required, the retry writes its connections once its container is running.
+func rejectOrRetry(delivery rmq.Delivery, peer config.Peer, setup bool, err error) {
+	if errors.Is(err, k8s.ErrContainerNotReady) {
+		log.Info("The container of the node [", peer.NodeName, "] is not ready, adding it to the retry queue")
+		if setup {
+			AddSetupToQueue(peer)
+		} else {
+			AddToQueue(peer)
+		}
+		if err := delivery.Ack(); err != nil {
+			log.Error("Error: ", err)
+		}
+		return
+	}
+
+	if err := delivery.Reject(); err != nil {
+		log.Error("Error: ", err)
+	}
 }
 
-func logErrors(errChan <-chan error) {
-	for err := range errChan {
+// logErrors logs the errors of the queue connection until the context is done.
+func logErrors(ctx context.Context, errChan <-chan error) {
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err = <-errChan:
+		}
+
 		switch err := err.(type) {
 		case *rmq.HeartbeatError:
 			if err.Count == rmq.HeartbeatErrorLimit {
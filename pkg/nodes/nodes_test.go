@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
 )
 
 func TestValidateNode(t *testing.T) {
@@ -76,3 +78,141 @@ func TestValidateNode(t *testing.T) {
 		})
 	}
 }
+
+func TestResolvePeer(t *testing.T) {
+	cfg := config.MutualPeersConfig{
+		MutualPeers: []*config.MutualPeer{
+			{
+				Peers: []config.Peer{
+					{NodeName: "consensus-full-1-0", NodeType: "consensus", ContainerName: "celestia-app"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		task redis.NodeTask
+		want config.Peer
+	}{
+		{
+			name: "Case 1: Node in the config keeps its containers",
+			task: redis.NodeTask{NodeName: "consensus-full-1-0", Namespace: "celestia", NodeType: "consensus"},
+			want: config.Peer{
+				NodeName:           "consensus-full-1-0",
+				NodeType:           "consensus",
+				Namespace:          "celestia",
+				ContainerName:      "celestia-app",
+				ContainerSetupName: "consensus-setup",
+			},
+		},
+		{
+			name: "Case 2: Node not in the config uses the defaults of its type",
+			task: redis.NodeTask{NodeName: "da-bridge-4-0", Namespace: "celestia", NodeType: "da"},
+			want: config.Peer{
+				NodeName:           "da-bridge-4-0",
+				NodeType:           "da",
+				Namespace:          "celestia",
+				ContainerName:      "da",
+				ContainerSetupName: "da-setup",
+			},
+		},
+		{
+			name: "Case 3: Node not in the config uses the values declared by the pod",
+			task: redis.NodeTask{
+				NodeName:      "celestia-light-0",
+				Namespace:     "celestia",
+				NodeType:      "da",
+				ContainerName: "light",
+				ConnectsTo:    []string{"da-bridge-1-0"},
+			},
+			want: config.Peer{
+				NodeName:           "celestia-light-0",
+				NodeType:           "da",
+				Namespace:          "celestia",
+				ContainerName:      "light",
+				ContainerSetupName: "da-setup",
+				ConnectsTo:         []string{"da-bridge-1-0"},
+			},
+		},
+		{
+			name: "Case 4: Node in the config ignores the container declared by the pod",
+			task: redis.NodeTask{NodeName: "consensus-full-1-0", Namespace: "celestia", ContainerName: "other"},
+			want: config.Peer{
+				NodeName:           "consensus-full-1-0",
+				NodeType:           "consensus",
+				Namespace:          "celestia",
+				ContainerName:      "celestia-app",
+				ContainerSetupName: "consensus-setup",
+			},
+		},
+		{
+			name: "Case 5: Bare pod name from previous versions uses the type of its name",
+			task: redis.NodeTask{NodeName: "consensus-validator-4-0", Legacy: true},
+			want: config.Peer{
+				NodeName:           "consensus-validator-4-0",
+				NodeType:           "consensus",
+				Namespace:          k8s.GetCurrentNamespace(),
+				ContainerName:      "consensus",
+				ContainerSetupName: "consensus-setup",
+			},
+		},
+		{
+			name: "Case 6: Bare pod name from previous versions is a DA node by default",
+			task: redis.NodeTask{NodeName: "celestia-light-0", Legacy: true},
+			want: config.Peer{
+				NodeName:           "celestia-light-0",
+				NodeType:           "da",
+				Namespace:          k8s.GetCurrentNamespace(),
+				ContainerName:      "da",
+				ContainerSetupName: "da-setup",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolvePeer(tt.task, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolvePeer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		peer     config.Peer
+		wantName string
+		wantKey  string
+	}{
+		{
+			name:     "Case 1: ConfigMap of the node",
+			peer:     config.Peer{NodeName: "da-full-1-0", Delivery: config.Delivery{Mode: "configMap"}},
+			wantName: "torch-da-full-1-0",
+			wantKey:  "TP-ADDR",
+		},
+		{
+			name:     "Case 2: ConfigMap shared by the network",
+			peer:     config.Peer{NodeName: "da-full-1-0", Delivery: config.Delivery{Mode: "configMap", Name: "torch-peers"}},
+			wantName: "torch-peers",
+			wantKey:  "da-full-1-0",
+		},
+		{
+			name:     "Case 3: Key defined in the config",
+			peer:     config.Peer{NodeName: "da-full-1-0", Delivery: config.Delivery{Mode: "secret", Key: "peers"}},
+			wantName: "torch-da-full-1-0",
+			wantKey:  "peers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeliveryName(tt.peer); got != tt.wantName {
+				t.Errorf("DeliveryName() = %v, want %v", got, tt.wantName)
+			}
+			if got := DeliveryKey(tt.peer, fPathDA); got != tt.wantKey {
+				t.Errorf("DeliveryKey() = %v, want %v", got, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package nodes
+
+import (
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+const (
+	defaultWorkerPoolSize = 5       // defaultWorkerPoolSize number of nodes processed in parallel when WORKER_POOL_SIZE is empty.
+	taskPoolName          = "tasks" // taskPoolName name of the pool used to process the tasks of the retry queue.
+)
+
+var (
+	pools     = make(map[string]*Pool) // pools created by name, so they are shared when the workers are restarted.
+	poolsLock sync.Mutex
+)
+
+// Pool runs the tasks of independent nodes in parallel, limiting the number of tasks running at the same time.
+type Pool struct {
+	name  string
+	size  int
+	slots chan struct{}
+	inUse atomic.Int64
+	wg    sync.WaitGroup
+}
+
+// GetWorkerPoolSize returns the number of nodes that Torch processes in parallel, it can be changed with WORKER_POOL_SIZE.
+func GetWorkerPoolSize() int {
+	return k8s.GetEnvInt("WORKER_POOL_SIZE", defaultWorkerPoolSize)
+}
+
+// NewPool returns a pool that runs up to size tasks at the same time, and registers its utilization metrics.
+func NewPool(name string, size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	p := &Pool{
+		name:  name,
+		size:  size,
+		slots: make(chan struct{}, size),
+	}
+	if err := metrics.WithMetricsPool(name, int64(size), p.InUse); err != nil {
+		log.Error("Error registering the metrics of the pool: [", name, "]: ", err)
+	}
+	return p
+}
+
+// GetPool returns the pool with the name, it is created with WORKER_POOL_SIZE workers the first time.
+func GetPool(name string) *Pool {
+	poolsLock.Lock()
+	defer poolsLock.Unlock()
+
+	p, ok := pools[name]
+	if !ok {
+		p = NewPool(name, GetWorkerPoolSize())
+		pools[name] = p
+	}
+	return p
+}
+
+// Go waits until there is a free worker and runs the task in a new goroutine.
+func (p *Pool) Go(task func()) {
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.slots }()
+		p.track(task)
+	}()
+}
+
+// Run waits until there is a free worker and runs the task in the current goroutine.
+func (p *Pool) Run(task func()) {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+	p.track(task)
+}
+
+// Wait waits until all the tasks started with Go finish.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// Size returns the max number of tasks running at the same time.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// InUse returns the number of tasks running.
+func (p *Pool) InUse() int64 {
+	return p.inUse.Load()
+}
+
+// track runs the task updating the number of tasks running.
+func (p *Pool) track(task func()) {
+	p.inUse.Add(1)
+	defer p.inUse.Add(-1)
+	task()
+}
@@ -0,0 +1,46 @@
+package nodes
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	pool := NewPool("test", 2)
+
+	// Case 1: the pool never runs more tasks than its size at the same time.
+	var running, maxRunning, done atomic.Int64
+	for i := 0; i < 10; i++ {
+		pool.Go(func() {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+	}
+	pool.Wait()
+
+	if got := done.Load(); got != 10 {
+		t.Errorf("tasks done = %v, want 10", got)
+	}
+	if got := maxRunning.Load(); got > 2 {
+		t.Errorf("max tasks running = %v, want <= 2", got)
+	}
+
+	// Case 2: the tasks run with Run are tracked while they are running.
+	pool.Run(func() {
+		if got := pool.InUse(); got != 1 {
+			t.Errorf("InUse() = %v, want 1", got)
+		}
+	})
+	if got := pool.InUse(); got != 0 {
+		t.Errorf("InUse() = %v, want 0", got)
+	}
+}
@@ -2,36 +2,58 @@ package nodes
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
 	"github.com/jrmanes/torch/pkg/metrics"
 )
 
 var (
-	taskQueue                   = make(chan config.Peer) // taskQueue channel for pending tasks (peers to process later).
-	MaxRetryCount               = 5                      // MaxRetryCount number of retries per node.
-	TickerTime                  = 5 * time.Second        // TickerTime time specified to make a signal.
-	timeoutDurationProcessQueue = 60 * time.Second       // timeoutDurationProcessQueue time specified to make a signal.
+	MaxRetryCount               = 5                // MaxRetryCount default number of retries per node, it can be overridden with the peer retryCount.
+	TickerTime                  = 5 * time.Second  // TickerTime time specified to check the tasks that are due.
+	timeoutDurationProcessQueue = 60 * time.Second // timeoutDurationProcessQueue max time to process a node from the queue.
+	retryBaseDelay              = 5 * time.Second  // retryBaseDelay delay before the first retry, it is doubled on every attempt.
+	retryMaxDelay               = 5 * time.Minute  // retryMaxDelay max delay between retries.
+	retryVisibilityTimeout      = 5 * time.Minute  // retryVisibilityTimeout time before a claimed task that wasn't completed is processed again.
+	retryBatchSize              = 10               // retryBatchSize max number of tasks processed on every tick.
 )
 
-// ProcessTaskQueue processes the pending tasks in the queue the time specified in the const TickerTime.
-func ProcessTaskQueue() {
+var (
+	// ErrEmptyNodeId is returned when Torch couldn't get the id of the node.
+	ErrEmptyNodeId = errors.New("the node id is empty")
+	// ErrSetupFailed is returned when Torch couldn't write the connections of the node.
+	ErrSetupFailed = errors.New("error writing the connections of the node")
+)
+
+// ProcessTaskQueue processes the pending tasks in the queue the time specified in the const TickerTime,
+// it returns when the context is done, the tasks already claimed are processed before returning.
+func ProcessTaskQueue(ctx context.Context) error {
 	ticker := time.NewTicker(TickerTime)
+	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
 		case <-ticker.C:
 			processQueue()
 		}
 	}
 }
 
-// processQueue process the nodes in the queue and tries to generate the Multi Address
+// processQueue process the nodes that are due in the queue in parallel and tries to generate the Multi Address,
+// the nodes that fail are retried with an exponential backoff until they reach the max number of retries.
 func processQueue() {
+	pool := GetPool(taskPoolName)
+
 	red := redis.InitRedisConfig()
 	// Create a new context with a timeout
 	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationProcessQueue)
@@ -39,22 +61,69 @@ func processQueue() {
 	// Make sure to call the cancel function to release resources when you're done
 	defer cancel()
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Error("processQueue - The context has been canceled, exit the loop.")
-			return
-		case peer := <-taskQueue:
-			// TODO:
-			// errors should be returned back and go routines needs to be in errGroup instead of pure go
-			err := CheckNodesInDBOrCreateThem(peer, red, ctx)
-			if err != nil {
-				log.Error("Error checking the nodes: CheckNodesInDBOrCreateThem - ", err)
-			}
-
-		default:
-			return
+	// claim enough tasks to keep all the workers busy.
+	limit := retryBatchSize
+	if pool.Size() > limit {
+		limit = pool.Size()
+	}
+
+	tasks, err := red.ClaimDueRetries(ctx, time.Now(), limit, retryVisibilityTimeout)
+	if err != nil {
+		log.Error("Error getting the tasks from the queue: ", err)
+		return
+	}
+
+	// every task belongs to a different node, so they can be processed at the same time.
+	for _, task := range tasks {
+		task := task
+		pool.Go(func() {
+			processTask(red, task)
+		})
+	}
+	pool.Wait()
+}
+
+// processTask tries to generate the Multi Address of the node, completing the task if it succeeds or rescheduling it
+// otherwise.
+func processTask(red *redis.RedisClient, task redis.RetryTask) {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationProcessQueue)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	err := CheckNodesInDBOrCreateThem(task.Peer, red, ctx)
+	if err == nil {
+		err = setupConnections(task)
+	}
+	if err == nil {
+		if err := red.CompleteRetry(ctx, task.Peer.NodeName); err != nil {
+			log.Error("Error removing the node from the queue: [", task.Peer.NodeName, "]: ", err)
 		}
+		return
+	}
+
+	log.Error("Error processing the node in the queue: [", task.Peer.NodeName, "]: ", err)
+	task.Attempt++
+	task.LastError = err.Error()
+
+	// check if the node is still under the maximum number of retries
+	if task.Attempt >= GetMaxRetryCount(task.Peer) {
+		log.Error("Max retry count reached for node: ", "[", task.Peer.NodeName, "]", " moving it to the dead letter set...")
+		if err := red.DeadLetter(ctx, task); err != nil {
+			log.Error("Error moving the node to the dead letter set: [", task.Peer.NodeName, "]: ", err)
+		}
+		recordPodEvent(task.Peer.Cluster, SetNodeDefault(task.Peer).Namespace, task.Peer.NodeName, corev1.EventTypeWarning,
+			k8s.EventMaxRetriesReached, "Max retry count reached after [%d] attempts, last error: %s",
+			task.Attempt, task.LastError)
+		return
+	}
+
+	delay := RetryDelay(task.Attempt)
+	task.NextAttempt = time.Now().Add(delay).UTC()
+	log.Info("Node ", "["+task.Peer.NodeName+"]"+" will be retried in: [", delay, "], attempt: ", "[", task.Attempt, "]")
+	if err := red.EnqueueRetry(ctx, task); err != nil {
+		log.Error("Error adding the node to the queue: [", task.Peer.NodeName, "]: ", err)
 	}
 }
 
@@ -69,44 +138,126 @@ func CheckNodesInDBOrCreateThem(peer config.Peer, red *redis.RedisClient, ctx co
 	}
 
 	// if the node doesn't exist in the DB, let's try to create it
+	if ma == "" && peer.NodeType == "consensus" {
+		log.Info("Consensus node ", "["+peer.NodeName+"]"+" NOT found in DB, let's try to get its id")
+		ma, err = GetConsensusNodeIdAndSaveIt(peer, red, ctx)
+		if err != nil {
+			log.Error("Error GetConsensusNodeIdAndSaveIt for node: [", peer.NodeName, "]", err)
+			return err
+		}
+	}
 	if ma == "" {
 		log.Info("Node ", "["+peer.NodeName+"]"+" NOT found in DB, let's try to generate it")
 		ma, err = GenerateNodeIdAndSaveIt(peer, peer.NodeName, red, ctx)
 		if err != nil {
 			log.Error("Error GenerateNodeIdAndSaveIt for full-node: [", peer.NodeName, "]", err)
+			return err
 		}
-		return err
 	}
 
 	// check if the multi address is empty after trying to generate it
 	if ma == "" {
-		// check if the node is still under the maximum number of retries
-		if peer.RetryCount < MaxRetryCount {
-			log.Info("Node ", "["+peer.NodeName+"]"+" NOT found in DB, adding it to the queue, attempt: ", "[", peer.RetryCount, "]")
-			peer.RetryCount++ // increment the counter
-			AddToQueue(peer)
-		} else {
-			log.Info("Max retry count reached for node: ", "[", peer.NodeName, "]", "it might have some issues...")
-		}
-	} else {
-		log.Info("Node ", "[", peer.NodeName, "]", " found in DB, ID: ", "[", ma, "]")
-		// Register a multi-address metric
-		m := metrics.MultiAddrs{
-			ServiceName: "torch",
-			NodeName:    peer.NodeName,
-			MultiAddr:   ma,
-			Namespace:   peer.Namespace,
-			Value:       1,
-		}
-		metrics.RegisterMetric(m)
+		return ErrEmptyNodeId
+	}
+
+	log.Info("Node ", "[", peer.NodeName, "]", " found in DB, ID: ", "[", ma, "]")
+	// Register a multi-address metric
+	m := metrics.MultiAddrs{
+		ServiceName: "torch",
+		NodeName:    peer.NodeName,
+		MultiAddr:   ma,
+		Namespace:   peer.Namespace,
+		Value:       1,
+	}
+	metrics.RegisterMetric(m)
+
+	// the Load Balancer might not have an address yet, the reconciler generates it later.
+	if peer.NodeType != "consensus" {
+		UpdateExternalMultiAddr(ctx, red, peer, ma)
 	}
 
 	return nil
 }
 
-// AddToQueue adds a function to add peers to the queue if necessary.
+// AddToQueue adds the peer to the queue to generate its Multi Address, it is processed in the next tick. If the node
+// is already in the queue, it keeps its attempts and its next attempt, and if it is in the dead letter set, it stays
+// there until it is replayed.
 func AddToQueue(peer config.Peer) {
-	peer.RetryCount = 0 // set the first attempt
-	log.Info("Node added to the queue: ", peer)
-	taskQueue <- peer
+	addToQueue(redis.RetryTask{Peer: peer})
+}
+
+// AddSetupToQueue adds the peer to the queue like AddToQueue, and its connections are written too when it is
+// processed, even if the node was already in the queue.
+func AddSetupToQueue(peer config.Peer) {
+	addToQueue(redis.RetryTask{Peer: peer, Setup: true})
+}
+
+// addToQueue adds the task to the queue to be processed in the next tick.
+func addToQueue(task redis.RetryTask) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationProcessQueue)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	task.Attempt = 0 // set the first attempt
+	task.NextAttempt = time.Now().UTC()
+	added, err := red.AddRetry(ctx, task)
+	switch {
+	case errors.Is(err, redis.ErrTaskDeadLettered):
+		log.Warn("Node [", task.Peer.NodeName, "] is in the dead letter set, replay it to retry it")
+	case err != nil:
+		log.Error("Error adding the node to the queue: [", task.Peer.NodeName, "]: ", err)
+	case added:
+		log.Info("Node added to the queue: ", task.Peer.NodeName)
+	default:
+		log.Info("Node already in the queue: ", task.Peer.NodeName)
+	}
+}
+
+// setupConnections writes the connections of the node when the task requires it, they are written like the reconciler
+// does, in the container of the node that is running, so they are written even if the setup container has already
+// finished.
+func setupConnections(task redis.RetryTask) error {
+	if !task.Setup {
+		return nil
+	}
+
+	status, ok := ReconcilePeer(task.Peer)
+	if ok && status.State == ReconcileFailed {
+		return fmt.Errorf("%w: [%s]: %s", ErrSetupFailed, task.Peer.NodeName, status.Error)
+	}
+	return nil
+}
+
+// GetMaxRetryCount returns the max number of retries for the peer, using the retryCount of the config if it is defined.
+func GetMaxRetryCount(peer config.Peer) int {
+	if peer.RetryCount > 0 {
+		return peer.RetryCount
+	}
+	return MaxRetryCount
+}
+
+// RetryDelay returns the delay before the next attempt, it grows exponentially with the attempts up to retryMaxDelay,
+// and a random jitter is applied, so the nodes that failed at the same time are not retried at the same time.
+func RetryDelay(attempt int) time.Duration {
+	return retryDelay(attempt, rand.Float64())
+}
+
+// retryDelay returns the delay for the attempt, jitter must be in [0, 1) and the result is in [delay/2, delay).
+func retryDelay(attempt int, jitter float64) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 1 {
+		attempt = 1
+	}
+	// avoid overflows, after that many attempts we are already at the max delay.
+	if attempt < 32 {
+		if d := retryBaseDelay << (attempt - 1); d > 0 && d < retryMaxDelay {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	return half + time.Duration(jitter*float64(half))
 }
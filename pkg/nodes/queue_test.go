@@ -0,0 +1,74 @@
+package nodes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jrmanes/torch/config"
+)
+
+func TestRetryDelay(t *testing.T) {
+	type args struct {
+		attempt int
+		jitter  float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want time.Duration
+	}{
+		{
+			name: "Case 1: First attempt without jitter",
+			args: args{attempt: 1, jitter: 0},
+			want: 2500 * time.Millisecond,
+		},
+		{
+			name: "Case 2: Third attempt with max jitter",
+			args: args{attempt: 3, jitter: 0.999},
+			want: 10*time.Second + time.Duration(0.999*float64(10*time.Second)),
+		},
+		{
+			name: "Case 3: Delay is capped",
+			args: args{attempt: 20, jitter: 0},
+			want: retryMaxDelay / 2,
+		},
+		{
+			name: "Case 4: Huge attempts don't overflow",
+			args: args{attempt: 100, jitter: 0},
+			want: retryMaxDelay / 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryDelay(tt.args.attempt, tt.args.jitter); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMaxRetryCount(t *testing.T) {
+	tests := []struct {
+		name string
+		peer config.Peer
+		want int
+	}{
+		{
+			name: "Case 1: Default retries",
+			peer: config.Peer{NodeName: "da-full-1-0"},
+			want: MaxRetryCount,
+		},
+		{
+			name: "Case 2: Retries from the config",
+			peer: config.Peer{NodeName: "da-full-1-0", RetryCount: 10},
+			want: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetMaxRetryCount(tt.peer); got != tt.want {
+				t.Errorf("GetMaxRetryCount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+const (
+	ReconcileInSync        = "in_sync"       // ReconcileInSync the file of the node already had the desired content.
+	ReconcileUpdated       = "updated"       // ReconcileUpdated the file of the node was rewritten with the desired content.
+	ReconcileFailed        = "failed"        // ReconcileFailed the node couldn't be reconciled.
+	reconcilePoolName      = "reconcile"     // reconcilePoolName name of the pool used to reconcile the nodes.
+	defaultReconcilePeriod = 5 * time.Minute // defaultReconcilePeriod how often the nodes are reconciled when RECONCILE_PERIOD is empty.
+)
+
+// GetReconcilePeriod returns how often Torch reconciles the nodes of the config, it can be changed with RECONCILE_PERIOD.
+func GetReconcilePeriod() time.Duration {
+	period := os.Getenv("RECONCILE_PERIOD")
+	if period == "" {
+		return defaultReconcilePeriod
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		log.Error("Invalid RECONCILE_PERIOD [", period, "], using the default value: ", defaultReconcilePeriod)
+		return defaultReconcilePeriod
+	}
+	return d
+}
+
+// RunReconciler reconciles the nodes of the config when it starts and every reconcile period until the context is
+// done.
+func RunReconciler(ctx context.Context, cfg config.MutualPeersConfig) error {
+	ReconcileAll(cfg)
+
+	ticker := time.NewTicker(GetReconcilePeriod())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			ReconcileAll(cfg)
+		}
+	}
+}
+
+// ReconcileAll reconciles all the nodes of the config in parallel.
+func ReconcileAll(cfg config.MutualPeersConfig) {
+	log.Info("Reconciling the nodes...")
+	pool := GetPool(reconcilePoolName)
+
+	seen := map[string]bool{}
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if seen[peer.NodeName] {
+				continue
+			}
+			seen[peer.NodeName] = true
+
+			peer := peer
+			pool.Go(func() {
+				ReconcilePeer(peer)
+			})
+		}
+	}
+	pool.Wait()
+}
+
+// ReconcilePeer compares the content of the file that Torch writes in the node with the desired one and rewrites it
+// when they differ, the result is stored as the reconcile status of the node. The nodes without connections are
+// skipped.
+func ReconcilePeer(peer config.Peer) (redis.ReconcileStatus, bool) {
+	red := redis.InitRedisConfig()
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	peer = SetNodeDefault(peer)
+	refreshExternalMultiAddr(ctx, red, peer)
+
+	file, ok := nodeFile(peer)
+	if !ok {
+		return redis.ReconcileStatus{}, false
+	}
+
+	status := redis.ReconcileStatus{
+		NodeName: peer.NodeName,
+		File:     file,
+	}
+	status.State, status.Desired, status.Actual, status.Error = reconcileFile(ctx, red, peer, file)
+	status.ReconciledAt = time.Now().UTC()
+
+	if status.State == ReconcileFailed {
+		log.Error("Error reconciling the node: [", peer.NodeName, "]: ", status.Error)
+	} else {
+		log.Info("Node [", peer.NodeName, "] reconciled: [", status.State, "]")
+	}
+
+	if err := red.SetReconcileStatus(ctx, status); err != nil {
+		log.Error("Error storing the reconcile status of the node: [", peer.NodeName, "]: ", err)
+	}
+	metrics.RecordReconcileStatus(metrics.ReconcileStatus{
+		NodeName:  peer.NodeName,
+		Namespace: peer.Namespace,
+		State:     status.State,
+		Timestamp: status.ReconciledAt.Unix(),
+	})
+
+	return status, true
+}
+
+// refreshExternalMultiAddr generates again the external multi address of the node, so it follows the changes of the
+// address of its Load Balancer.
+func refreshExternalMultiAddr(ctx context.Context, red *redis.RedisClient, peer config.Peer) {
+	if peer.ExternalService == "" || peer.NodeType == "consensus" {
+		return
+	}
+
+	id, err := red.GetKey(ctx, peer.NodeName)
+	if err != nil {
+		log.Error("Error getting the id of the node: [", peer.NodeName, "]: ", err)
+		return
+	}
+	UpdateExternalMultiAddr(ctx, red, peer, id)
+}
+
+// reconcileFile computes the desired content of the file, reads the actual one from the node and rewrites it when
+// they differ. It returns the state, the desired and the actual content and the error if any.
+func reconcileFile(
+	ctx context.Context,
+	red *redis.RedisClient,
+	peer config.Peer,
+	file string,
+) (string, string, string, string) {
+	desired, err := desiredContent(ctx, red, peer)
+	if err != nil {
+		return ReconcileFailed, "", "", err.Error()
+	}
+
+	// the setup container is running while the node waits for the file, otherwise we use the main container.
+	container := ""
+	if DeliveryMode(peer) == k8s.DeliveryExec {
+		container, err = k8s.GetRunningContainer(
+			k8s.WithCluster(ctx, peer.Cluster),
+			k8s.ClusterNamespace(peer.Cluster),
+			peer.NodeName,
+			peer.ContainerSetupName,
+			peer.ContainerName,
+		)
+		if err != nil {
+			return ReconcileFailed, desired, "", err.Error()
+		}
+	}
+
+	// the files, the ConfigMaps and the Secrets that don't exist yet, e.g. a pod that restarted with a fresh volume,
+	// are created with the desired content.
+	actual, err := ReadNodeFile(ctx, peer, container, file)
+	if err != nil && !errors.Is(err, k8s.ErrKeyNotFound) && !errors.Is(err, k8s.ErrFileNotFound) {
+		return ReconcileFailed, desired, "", err.Error()
+	}
+	actual = strings.TrimSpace(actual)
+
+	if actual == desired {
+		return ReconcileInSync, desired, actual, ""
+	}
+
+	log.Info("Node [", peer.NodeName, "] out of sync, file: [", file, "] desired: [", desired, "] actual: [", actual, "]")
+	if err := WriteNodeFile(ctx, red, peer, container, file, desired); err != nil {
+		return ReconcileFailed, desired, actual, err.Error()
+	}
+
+	return ReconcileUpdated, desired, actual, ""
+}
+
+// nodeFile returns the path of the file that Torch writes in the node, false if Torch doesn't write any file.
+func nodeFile(peer config.Peer) (string, bool) {
+	switch {
+	case len(peer.ConnectsTo) == 0:
+		return "", false
+	case peer.ConnectsAsEnvVar:
+		return k8s.EnvVarFilePath(peer.NodeType), k8s.EnvVarFilePath(peer.NodeType) != ""
+	case peer.NodeType == "da":
+		return fPathDA, true
+	}
+	return "", false
+}
+
+// desiredContent returns the content that the file of the node must have.
+func desiredContent(ctx context.Context, red *redis.RedisClient, peer config.Peer) (string, error) {
+	if peer.ConnectsAsEnvVar {
+		return peer.ConnectsTo[0], nil
+	}
+	return BuildConnectionString(peer, red, ctx)
+}
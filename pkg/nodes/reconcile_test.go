@@ -0,0 +1,132 @@
+package nodes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/k8s/fake"
+)
+
+func TestReconcilePeer(t *testing.T) {
+	peer := config.Peer{
+		NodeName:       "da-full-1-0",
+		NodeType:       "da",
+		DnsConnections: []string{"da-bridge-1"},
+		ConnectsTo:     []string{"da-bridge-1-0"},
+	}
+	desired := "/dns/da-bridge-1/tcp/2121/p2p/" + testNodeID
+
+	tests := []struct {
+		name       string
+		actual     *string
+		setup      func(executor *fake.Executor)
+		wantState  string
+		wantActual string
+		wantFile   string
+		wantEvent  bool // wantEvent true when the read of the file is reported as a failed command.
+	}{
+		{
+			name:       "Case 1: the file already has the desired content",
+			actual:     &desired,
+			wantState:  ReconcileInSync,
+			wantActual: desired,
+			wantFile:   desired,
+		},
+		{
+			name:       "Case 2: the file has drifted",
+			actual:     stringPtr("/dns/da-bridge-2/tcp/2121/p2p/" + testNodeID),
+			wantState:  ReconcileUpdated,
+			wantActual: "/dns/da-bridge-2/tcp/2121/p2p/" + testNodeID,
+			wantFile:   desired,
+		},
+		{
+			name:      "Case 3: the pod restarted with a fresh volume and the file is missing",
+			wantState: ReconcileUpdated,
+			wantFile:  desired,
+		},
+		{
+			name:   "Case 4: the file can't be read",
+			actual: stringPtr("old"),
+			setup: func(executor *fake.Executor) {
+				executor.On("cat", func(k8s.ExecRequest, string) (k8s.ExecResult, error) {
+					return k8s.ExecResult{Stderr: "Permission denied", ExitCode: 1}, nil
+				})
+			},
+			wantState: ReconcileFailed,
+			wantFile:  "old",
+			wantEvent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor, red, recorder := newTestEnv(t)
+			ctx := context.Background()
+			namespace := k8s.GetCurrentNamespace()
+			if tt.actual != nil {
+				executor.SetFile(namespace, peer.NodeName, fPathDA, *tt.actual)
+			}
+			if tt.setup != nil {
+				tt.setup(executor)
+			}
+
+			status, ok := ReconcilePeer(peer)
+			if !ok {
+				t.Fatalf("ReconcilePeer() skipped the node")
+			}
+			if status.State != tt.wantState || status.Actual != tt.wantActual {
+				t.Errorf("ReconcilePeer() = %+v, want state %v and actual %q", status, tt.wantState, tt.wantActual)
+			}
+			if got, _ := executor.File(namespace, peer.NodeName, fPathDA); got != tt.wantFile {
+				t.Errorf("file = %v, want %v", got, tt.wantFile)
+			}
+
+			event := false
+			for len(recorder.Events) > 0 {
+				if strings.Contains(<-recorder.Events, k8s.EventExecFailed) {
+					event = true
+				}
+			}
+			if event != tt.wantEvent {
+				t.Errorf("event %v recorded = %v, want %v", k8s.EventExecFailed, event, tt.wantEvent)
+			}
+
+			stored, err := red.GetReconcileStatus(ctx, peer.NodeName)
+			if err != nil || stored.State != tt.wantState {
+				t.Errorf("GetReconcileStatus() = %+v, %v, want state %v", stored, err, tt.wantState)
+			}
+		})
+	}
+}
+
+// stringPtr returns a pointer to the string.
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestRunReconciler(t *testing.T) {
+	executor, red, _ := newTestEnv(t)
+	cfg := config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{{
+		NodeName:       "da-full-1-0",
+		NodeType:       "da",
+		DnsConnections: []string{"da-bridge-1"},
+		ConnectsTo:     []string{"da-bridge-1-0"},
+	}}}}}
+
+	// the context is done, so only the pass done when it starts is run.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RunReconciler(ctx, cfg); err != nil {
+		t.Fatalf("RunReconciler() error = %v", err)
+	}
+
+	if got, _ := executor.File(k8s.GetCurrentNamespace(), "da-full-1-0", fPathDA); got != "/dns/da-bridge-1/tcp/2121/p2p/"+testNodeID {
+		t.Errorf("file = %v, want the connections of the node", got)
+	}
+	if status, err := red.GetReconcileStatus(context.Background(), "da-full-1-0"); err != nil || status.State != ReconcileUpdated {
+		t.Errorf("GetReconcileStatus() = %+v, %v, want state %v", status, err, ReconcileUpdated)
+	}
+}
@@ -0,0 +1,88 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	minBackoff = 1 * time.Second // minBackoff time to wait before restarting a worker for the first time.
+	maxBackoff = 1 * time.Minute // maxBackoff max time to wait before restarting a worker.
+)
+
+// Worker is a long-running function, it must return when the context is done.
+// Returning nil means that the worker finished its job, returning an error means that it has to be restarted.
+type Worker func(ctx context.Context) error
+
+// Supervisor runs a group of workers sharing the same context, restarting them with a backoff when they fail.
+type Supervisor struct {
+	ctx   context.Context
+	group *errgroup.Group
+}
+
+// New returns a supervisor whose workers stop when the context is done.
+func New(ctx context.Context) *Supervisor {
+	group, ctx := errgroup.WithContext(ctx)
+	return &Supervisor{
+		ctx:   ctx,
+		group: group,
+	}
+}
+
+// Go starts the worker, if it fails or panics, it is restarted with an exponential backoff until the context is done.
+func (s *Supervisor) Go(name string, worker Worker) {
+	s.group.Go(func() error {
+		backoff := minBackoff
+		for {
+			started := time.Now()
+			err := run(s.ctx, worker)
+			if s.ctx.Err() != nil {
+				log.Info("Worker [", name, "] stopped")
+				return nil
+			}
+			if err == nil {
+				log.Info("Worker [", name, "] finished")
+				return nil
+			}
+
+			// the worker was running long enough, so it is not failing continuously.
+			if time.Since(started) > maxBackoff {
+				backoff = minBackoff
+			}
+
+			log.Error("Worker [", name, "] failed, restarting it in [", backoff, "]: ", err)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				log.Info("Worker [", name, "] stopped")
+				return nil
+			case <-timer.C:
+			}
+
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	})
+}
+
+// Wait blocks until all the workers are stopped.
+func (s *Supervisor) Wait() error {
+	return s.group.Wait()
+}
+
+// run executes the worker, converting the panics into errors.
+func run(ctx context.Context, worker Worker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return worker(ctx)
+}
@@ -0,0 +1,59 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	minBackoff = time.Millisecond
+	maxBackoff = 10 * time.Millisecond
+
+	// Case 1: a worker that fails or panics is restarted until it finishes.
+	var calls atomic.Int32
+	s := New(context.Background())
+	s.Go("flaky", func(ctx context.Context) error {
+		switch calls.Add(1) {
+		case 1:
+			return errors.New("failed")
+		case 2:
+			panic("boom")
+		}
+		return nil
+	})
+	if err := s.Wait(); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("worker calls = %v, want 3", got)
+	}
+
+	// Case 2: the workers stop when the context is done, even if they are failing.
+	ctx, cancel := context.WithCancel(context.Background())
+	s = New(ctx)
+	s.Go("blocking", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.Go("failing", func(ctx context.Context) error {
+		return errors.New("failed")
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- s.Wait()
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Wait() didn't return after the context was cancelled")
+	}
+}
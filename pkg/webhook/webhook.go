@@ -0,0 +1,319 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/nodes"
+)
+
+const (
+	defaultWebhookPort = "8443"                       // defaultWebhookPort port of the webhook when WEBHOOK_PORT is empty.
+	defaultCertFile    = "/etc/torch/webhook/tls.crt" // defaultCertFile certificate of the webhook when WEBHOOK_CERT_FILE is empty.
+	defaultKeyFile     = "/etc/torch/webhook/tls.key" // defaultKeyFile key of the webhook when WEBHOOK_KEY_FILE is empty.
+	MutatePath         = "/mutate"                    // MutatePath path called by the MutatingWebhookConfiguration.
+	EnvConsensusNode   = "CONSENSUS_NODE_SERVICE"     // EnvConsensusNode env var with the consensus node to connect to.
+	EnvTrustedPeers    = "TRUSTED_PEERS"              // EnvTrustedPeers env var with the multi addresses of the trusted peers.
+	requestTimeout     = 5 * time.Second              // requestTimeout max time to compute the env vars of a pod.
+	shutdownTimeout    = 5 * time.Second              // shutdownTimeout max time to wait for the requests when the webhook stops.
+	maxBodySize        = 3 * 1024 * 1024              // maxBodySize max size of the AdmissionReviews received.
+	jsonContentType    = "application/json"           // jsonContentType content type of the AdmissionReviews.
+	admissionAPI       = "admission.k8s.io/v1"        // admissionAPI apiVersion of the AdmissionReviews.
+	admissionKind      = "AdmissionReview"            // admissionKind kind of the AdmissionReviews.
+)
+
+// jsonPointerEscaper escapes the keys used in the JSON pointers of the patches.
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Enabled checks if Torch has to serve the admission webhook, it is enabled with WEBHOOK=true.
+func Enabled() bool {
+	return os.Getenv("WEBHOOK") == "true"
+}
+
+// GetPort returns the port of the webhook, it can be changed with WEBHOOK_PORT.
+func GetPort() string {
+	port := os.Getenv("WEBHOOK_PORT")
+	if port == "" {
+		return defaultWebhookPort
+	}
+
+	if _, err := strconv.Atoi(port); err != nil {
+		log.Error("Invalid WEBHOOK_PORT [", port, "], using the default port ", defaultWebhookPort)
+		return defaultWebhookPort
+	}
+	return port
+}
+
+// GetCertFiles returns the certificate and the key used by the webhook to serve TLS, they can be changed with
+// WEBHOOK_CERT_FILE and WEBHOOK_KEY_FILE.
+func GetCertFiles() (string, string) {
+	certFile := os.Getenv("WEBHOOK_CERT_FILE")
+	if certFile == "" {
+		certFile = defaultCertFile
+	}
+	keyFile := os.Getenv("WEBHOOK_KEY_FILE")
+	if keyFile == "" {
+		keyFile = defaultKeyFile
+	}
+	return certFile, keyFile
+}
+
+// Run serves the admission webhook with TLS until the context is done.
+func Run(ctx context.Context, cfg config.MutualPeersConfig) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(MutatePath, func(w http.ResponseWriter, r *http.Request) {
+		Mutate(w, r, cfg)
+	})
+	server := &http.Server{Addr: ":" + GetPort(), Handler: mux}
+
+	errCh := make(chan error, 1)
+	go func() {
+		certFile, keyFile := GetCertFiles()
+		log.Info("Admission webhook listening on port: ", GetPort())
+		errCh <- server.ListenAndServeTLS(certFile, keyFile)
+	}()
+
+	select {
+	case err := <-errCh:
+		log.Error("Error serving the admission webhook: ", err)
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Mutate handles the AdmissionReviews of the pods, injecting the env vars of the nodes of the config. The pods are
+// always allowed, when the env vars can't be computed they are created as they are and Torch sets them up later.
+func Mutate(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		log.Error("Invalid AdmissionReview received: ", err)
+		http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	response := admit(ctx, review.Request, cfg)
+	response.UID = review.Request.UID
+	out, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionAPI, Kind: admissionKind},
+		Response: response,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	if _, err := w.Write(out); err != nil {
+		log.Error("Error writing the AdmissionReview: ", err)
+	}
+}
+
+// admit returns the response of the AdmissionRequest, with the patch of the pod when it is a node of the config.
+func admit(ctx context.Context, req *admissionv1.AdmissionRequest, cfg config.MutualPeersConfig) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Kind.Kind != "Pod" || req.Operation != admissionv1.Create {
+		return response
+	}
+
+	pod := corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		log.Error("Error decoding the pod of the AdmissionRequest: ", err)
+		response.Warnings = []string{"torch: the pod couldn't be decoded: " + err.Error()}
+		return response
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+
+	env, err := PodEnv(ctx, cfg, pod)
+	if err != nil {
+		log.Warn("Error computing the env vars of the pod: [", pod.Name, "]: ", err)
+		response.Warnings = []string{"torch: " + err.Error()}
+	}
+
+	patch := envPatch(pod, env, connectionsInjected(cfg, pod, env))
+	if len(patch) == 0 {
+		return response
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		log.Error("Error encoding the patch of the pod: [", pod.Name, "]: ", err)
+		return response
+	}
+
+	log.Info("Injecting the env vars of the node: [", pod.Name, "], namespace: [", pod.Namespace, "]")
+	patchType := admissionv1.PatchTypeJSONPatch
+	response.Patch = data
+	response.PatchType = &patchType
+	return response
+}
+
+// PodEnv returns the env vars of the node of the pod: the consensus node to connect to and the multi addresses of its
+// trusted peers computed from the ids stored in the DB. The pods that are not nodes of the config, or that run in
+// other clusters, don't get any env var.
+func PodEnv(ctx context.Context, cfg config.MutualPeersConfig, pod corev1.Pod) ([]corev1.EnvVar, error) {
+	mutualPeer, peer, ok := findPeer(cfg, pod.Name, pod.Namespace)
+	if !ok || peer.Cluster != "" {
+		return nil, nil
+	}
+	if peer.Namespace == "" {
+		peer.Namespace = pod.Namespace
+	}
+	peer = nodes.SetNodeDefault(peer)
+
+	var env []corev1.EnvVar
+	if peer.ConnectsAsEnvVar && len(peer.ConnectsTo) > 0 {
+		return append(env, corev1.EnvVar{Name: EnvConsensusNode, Value: peer.ConnectsTo[0]}), nil
+	}
+	if peer.NodeType != "da" {
+		return env, nil
+	}
+
+	// the consensus node is declared in the group of the peer, or in the first group for the whole network.
+	consensusNode := mutualPeer.ConsensusNode
+	if consensusNode == "" {
+		consensusNode = cfg.MutualPeers[0].ConsensusNode
+	}
+	if consensusNode != "" {
+		env = append(env, corev1.EnvVar{Name: EnvConsensusNode, Value: consensusNode})
+	}
+	if len(peer.ConnectsTo) == 0 {
+		return env, nil
+	}
+
+	trustedPeers, err := nodes.StoredConnectionString(ctx, redis.InitRedisConfig(), peer)
+	if err != nil {
+		return env, err
+	}
+	return append(env, corev1.EnvVar{Name: EnvTrustedPeers, Value: trustedPeers}), nil
+}
+
+// connectionsInjected checks if the env vars have the connections of the node of the pod: the consensus node for the
+// nodes using ENV Vars and the trusted peers for the rest of the DA nodes, Torch doesn't write them in the pod then.
+func connectionsInjected(cfg config.MutualPeersConfig, pod corev1.Pod, env []corev1.EnvVar) bool {
+	_, peer, ok := findPeer(cfg, pod.Name, pod.Namespace)
+	if !ok {
+		return false
+	}
+
+	name := EnvTrustedPeers
+	if peer.ConnectsAsEnvVar {
+		name = EnvConsensusNode
+	}
+	for _, e := range env {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findPeer returns the peer of the node and the group of mutual peers where it is declared, the peers with a
+// namespace only match the pods of that namespace.
+func findPeer(cfg config.MutualPeersConfig, nodeName, namespace string) (*config.MutualPeer, config.Peer, bool) {
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if peer.NodeName == nodeName && (peer.Namespace == "" || peer.Namespace == namespace) {
+				return mutualPeer, peer, true
+			}
+		}
+	}
+	return nil, config.Peer{}, false
+}
+
+// patchOperation represents an operation of a JSON patch.
+type patchOperation struct {
+	Op    string      `json:"op"`              // Op operation, add.
+	Path  string      `json:"path"`            // Path JSON pointer of the value.
+	Value interface{} `json:"value,omitempty"` // Value added.
+}
+
+// envPatch returns the JSON patch that adds the env vars to the init containers and the containers of the pod, the env
+// vars already defined in a container are kept as they are. The pod is annotated when its connections are injected.
+func envPatch(pod corev1.Pod, env []corev1.EnvVar, injected bool) []patchOperation {
+	var patch []patchOperation
+	for i, container := range pod.Spec.InitContainers {
+		patch = append(patch, containerEnvPatch("/spec/initContainers/"+strconv.Itoa(i)+"/env", container, env)...)
+	}
+	for i, container := range pod.Spec.Containers {
+		patch = append(patch, containerEnvPatch("/spec/containers/"+strconv.Itoa(i)+"/env", container, env)...)
+	}
+	if len(patch) == 0 || !injected {
+		return patch
+	}
+
+	// the annotations of the pod are replaced when it doesn't have any, otherwise the annotation is added.
+	if pod.Annotations == nil {
+		return append(patch, patchOperation{
+			Op:    "add",
+			Path:  "/metadata/annotations",
+			Value: map[string]string{k8s.InjectedAnnotation: "true"},
+		})
+	}
+	return append(patch, patchOperation{
+		Op:    "add",
+		Path:  "/metadata/annotations/" + jsonPointerEscaper.Replace(k8s.InjectedAnnotation),
+		Value: "true",
+	})
+}
+
+// containerEnvPatch returns the operations that add the env vars missing in the container.
+func containerEnvPatch(path string, container corev1.Container, env []corev1.EnvVar) []patchOperation {
+	defined := map[string]bool{}
+	for _, e := range container.Env {
+		defined[e.Name] = true
+	}
+
+	var missing []corev1.EnvVar
+	for _, e := range env {
+		if !defined[e.Name] {
+			missing = append(missing, e)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// the list of env vars must exist before appending to it.
+	if container.Env == nil {
+		return []patchOperation{{Op: "add", Path: path, Value: missing}}
+	}
+
+	patch := make([]patchOperation, 0, len(missing))
+	for _, e := range missing {
+		patch = append(patch, patchOperation{Op: "add", Path: path + "/-", Value: e})
+	}
+	return patch
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+// testNodeID id of the bridge node stored in the DB.
+const testNodeID = "12D3KooWNFpkX9fuo3GQ38FaVKdAZcTQsLr1BNE5DTHGjv2fjEHG"
+
+// newPod returns the pod of a node with a setup container and a main container.
+func newPod(name string, setupEnv ...corev1.EnvVar) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "da-setup", Env: setupEnv}},
+			Containers:     []corev1.Container{{Name: "da"}},
+		},
+	}
+}
+
+// review sends the AdmissionReview of the pod to the webhook and returns the response.
+func review(t *testing.T, cfg config.MutualPeersConfig, pod *corev1.Pod) *admissionv1.AdmissionResponse {
+	t.Helper()
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Namespace: k8s.GetCurrentNamespace(),
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	Mutate(w, httptest.NewRequest(http.MethodPost, MutatePath, bytes.NewReader(body)), cfg)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	out := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out.Response == nil || !out.Response.Allowed || out.Response.UID != "uid-1" {
+		t.Fatalf("response = %+v, want the pod allowed", out.Response)
+	}
+	return out.Response
+}
+
+// patchPaths returns the paths of the operations of the patch and the env vars added.
+func patchPaths(t *testing.T, response *admissionv1.AdmissionResponse) map[string]interface{} {
+	t.Helper()
+	paths := map[string]interface{}{}
+	if response.Patch == nil {
+		return paths
+	}
+
+	var patch []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(response.Patch, &patch); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for _, op := range patch {
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		paths[op.Path] = value
+	}
+	return paths
+}
+
+// env returns the env var as it is decoded from the patch.
+func env(name, value string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "value": value}
+}
+
+func TestMutate(t *testing.T) {
+	server := miniredis.RunT(t)
+	t.Setenv("REDIS_HOST", server.Host())
+	t.Setenv("REDIS_PORT", server.Port())
+	if err := server.Set("da-bridge-1-0", testNodeID); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	k8s.SetClientSet(k8sfake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-1-0", Namespace: k8s.GetCurrentNamespace()},
+		Status:     corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}}},
+	}))
+	t.Cleanup(func() { k8s.SetClientSet(nil) })
+
+	cfg := config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{
+		{ConsensusNode: "consensus-validator-1"},
+		{Peers: []config.Peer{
+			{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsAsEnvVar: true, ConnectsTo: []string{"consensus-full-1"}},
+			{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-bridge-1-0"}},
+			{NodeName: "da-full-2-0", NodeType: "da", ConnectsTo: []string{"da-bridge-2-0"}},
+			{NodeName: "da-light-1-0", NodeType: "da", Cluster: "remote", ConnectsTo: []string{"da-bridge-1-0"}},
+			{NodeName: "da-full-3-0", NodeType: "da", Namespace: "celestia", ConnectsTo: []string{"da-bridge-1-0"}},
+		}},
+	}}
+	trustedPeers := "/ip4/10.0.0.1/tcp/2121/p2p/" + testNodeID
+	annotation := map[string]interface{}{k8s.InjectedAnnotation: "true"}
+
+	tests := []struct {
+		name         string
+		pod          *corev1.Pod
+		want         map[string]interface{}
+		wantWarnings bool
+	}{
+		{
+			name: "Case 1: DA node with the trusted peers stored",
+			pod:  newPod("da-full-1-0"),
+			want: map[string]interface{}{
+				"/spec/initContainers/0/env": []interface{}{
+					env(EnvConsensusNode, "consensus-validator-1"),
+					env(EnvTrustedPeers, trustedPeers),
+				},
+				"/spec/containers/0/env": []interface{}{
+					env(EnvConsensusNode, "consensus-validator-1"),
+					env(EnvTrustedPeers, trustedPeers),
+				},
+				"/metadata/annotations": annotation,
+			},
+		},
+		{
+			name: "Case 2: the env vars defined in the pod are kept",
+			pod:  newPod("da-full-1-0", corev1.EnvVar{Name: EnvTrustedPeers, Value: "/dns/da-bridge-1"}),
+			want: map[string]interface{}{
+				"/spec/initContainers/0/env/-": env(EnvConsensusNode, "consensus-validator-1"),
+				"/spec/containers/0/env": []interface{}{
+					env(EnvConsensusNode, "consensus-validator-1"),
+					env(EnvTrustedPeers, trustedPeers),
+				},
+				"/metadata/annotations": annotation,
+			},
+		},
+		{
+			name: "Case 3: node connecting with env vars",
+			pod:  newPod("da-bridge-1-0"),
+			want: map[string]interface{}{
+				"/spec/initContainers/0/env": []interface{}{env(EnvConsensusNode, "consensus-full-1")},
+				"/spec/containers/0/env":     []interface{}{env(EnvConsensusNode, "consensus-full-1")},
+				"/metadata/annotations":      annotation,
+			},
+		},
+		{
+			name: "Case 4: the id of the connection is not stored yet, the pod is not annotated",
+			pod:  newPod("da-full-2-0"),
+			want: map[string]interface{}{
+				"/spec/initContainers/0/env": []interface{}{env(EnvConsensusNode, "consensus-validator-1")},
+				"/spec/containers/0/env":     []interface{}{env(EnvConsensusNode, "consensus-validator-1")},
+			},
+			wantWarnings: true,
+		},
+		{
+			name: "Case 5: node of another cluster",
+			pod:  newPod("da-light-1-0"),
+			want: map[string]interface{}{},
+		},
+		{
+			name: "Case 6: pod that is not in the config",
+			pod:  newPod("nginx-0"),
+			want: map[string]interface{}{},
+		},
+		{
+			name: "Case 7: pod with the name of a node of the config in another namespace",
+			pod:  newPod("da-full-3-0"),
+			want: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := review(t, cfg, tt.pod)
+			if got := patchPaths(t, response); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patch = %v, want %v", got, tt.want)
+			}
+			if got := len(response.Warnings) > 0; got != tt.wantWarnings {
+				t.Errorf("warnings = %v, want warnings: %v", response.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestMutateInvalidReview(t *testing.T) {
+	w := httptest.NewRecorder()
+	Mutate(w, httptest.NewRequest(http.MethodPost, MutatePath, bytes.NewReader([]byte(`{}`))), config.MutualPeersConfig{})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}